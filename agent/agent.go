@@ -2,20 +2,23 @@ package agent
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"os"
-	"os/signal"
 	"oversee/collector/audit"
 	"oversee/core"
-	"syscall"
+	"oversee/pkg/tlsconfig"
+	"sync"
 	"time"
 
 	badger "github.com/dgraph-io/badger/v4"
-	"github.com/dgraph-io/ristretto/v2/z"
+	"github.com/dgraph-io/badger/v4/pb"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 	status "google.golang.org/grpc/status"
 	structpb "google.golang.org/protobuf/types/known/structpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
@@ -32,23 +35,81 @@ type DispatchMode int
 const (
 	DispatchModeBatch = iota + 1
 	DisptachModeIndividual
+	// DispatchModeStream pushes logs to the collector as they are logged,
+	// flushes only resending those that were not acknowledged.
+	DispatchModeStream
 )
 
+func ParseDispatchMode(mode string) (DispatchMode, error) {
+	switch mode {
+	case "batch":
+		return DispatchModeBatch, nil
+	case "individual":
+		return DisptachModeIndividual, nil
+	case "stream":
+		return DispatchModeStream, nil
+	default:
+		return 0, fmt.Errorf("unknown dispatch mode %q", mode)
+	}
+}
+
 type Agent struct {
 	Name         string
 	Application  Application
 	DispatchMode DispatchMode
 	db           *badger.DB
-	stream       *badger.Stream
 	bufferFile   *os.File
 
-	flushTime int
+	bufferDir     string
+	flushInterval time.Duration
+	batchSize     int
+	// allOrNothing asks the collector to persist no log of a batch when one
+	// of them is invalid.
+	allOrNothing bool
 
-	signals chan os.Signal
+	// SigningKey signs every request sent to the collector. Requests are
+	// sent unsigned when it is nil.
+	SigningKey ed25519.PrivateKey
+	// CollectorTLS configures the connection to the collector, which is
+	// plaintext when it is nil.
+	CollectorTLS *tlsconfig.Config
+
+	flushMu      sync.Mutex
+	stopFlushing context.CancelFunc
+	flushDone    chan struct{}
+
+	heartbeatInterval time.Duration
+	stopHeartbeat     context.CancelFunc
+	heartbeatDone     chan struct{}
 
 	collectorClient     audit.CollectorClient
 	collectorClientConn *grpc.ClientConn
-	collectorAddress    string
+	collectorEndpoints  []string
+	// logStream is only set in stream dispatch mode.
+	logStream *logStream
+
+	maxRecords int64
+	maxBytes   int64
+	overflow   OverflowPolicy
+	spillDir   string
+	encryption EncryptionConfig
+	// spill holds the logs that did not fit in the buffer with the spill
+	// overflow policy.
+	spill *badger.DB
+
+	// usageMu guards the fill level of the buffer and the spill.
+	usageMu sync.Mutex
+	// sequence is the last sequence number given to a log.
+	sequence uint64
+	records  int64
+	bytes    int64
+	spilled  int64
+
+	retry   retryPolicy
+	breaker *circuitBreaker
+	// maxRejections is how many times the collector may reject a log before
+	// it is dead-lettered.
+	maxRejections int
 }
 
 type Application struct {
@@ -58,10 +119,32 @@ type Application struct {
 }
 
 func (agent *Agent) newCollectorClient() (audit.CollectorClient, error) {
-	fmt.Println("Connecting to", agent.collectorAddress)
+	fmt.Println("Connecting to", agent.collectorEndpoints)
+	creds, err := agent.CollectorTLS.ClientCredentials()
+
+	if err != nil {
+		return nil, err
+	}
+
+	target := agent.collectorEndpoints[0]
+	options := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+
+	// With several endpoints, a manual resolver hands all of them to the
+	// default pick_first balancer, which fails over in order.
+	if len(agent.collectorEndpoints) > 1 {
+		r := manual.NewBuilderWithScheme("oversee")
+		addresses := []resolver.Address{}
+		for _, endpoint := range agent.collectorEndpoints {
+			addresses = append(addresses, resolver.Address{Addr: endpoint})
+		}
+		r.InitialState(resolver.State{Addresses: addresses})
+
+		target = r.Scheme() + ":///collector"
+		options = append(options, grpc.WithResolvers(r))
+	}
+
 	// Set up a connection to the server.
-	conn, err := grpc.NewClient(agent.collectorAddress,
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(target, options...)
 
 	if err != nil {
 		return nil, err
@@ -74,22 +157,49 @@ func (agent *Agent) newCollectorClient() (audit.CollectorClient, error) {
 	return c, nil
 }
 
-func (agent *Agent) shutdown() {
-	// Flush and gracefully close the buffer
-	agent.collectorClientConn.Close()
+// Log stores the log in the buffer, from which it is dispatched to the
+// collector on the next flush. A log without ID gets a new one, a log with
+// the ID of one stored recently is ignored.
+func (agent *Agent) Log(ctx context.Context, log *core.Log) error {
+	if agent.db == nil {
+		return ErrAgentNotStarted
+	}
 
-	agent.flushBuffer()
-	agent.db.Close()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	// Exit with code 0
-	os.Exit(0)
-}
+	if log.ID == uuid.Nil {
+		log.ID = uuid.New()
+	}
 
-func (agent *Agent) Log(log *core.Log) error {
-	return agent.db.Update(func(txn *badger.Txn) error {
-		err := txn.Set([]byte(log.ID.String()), log.Bytes())
+	value, err := log.Canonical()
+	if err != nil {
 		return err
-	})
+	}
+
+	spilled, err := agent.store(log, value)
+
+	// A retry of a log that is already stored succeeds without storing it
+	// again.
+	if errors.Is(err, errAlreadyStored) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Spilled logs are sent once they are moved back to the buffer.
+	if agent.logStream != nil && !spilled {
+		// The log is buffered, so it is only delayed to the next flush when
+		// the collector cannot be reached now.
+		if err = agent.logStream.sendLive(); err != nil {
+			fmt.Println("Streaming", log.ID, "failed:", err)
+		}
+	}
+
+	return nil
 }
 
 func CoreLogToLogsAPILog(log *core.Log) (*audit.Log, error) {
@@ -113,179 +223,434 @@ func CoreLogToLogsAPILog(log *core.Log) (*audit.Log, error) {
 	}, nil
 }
 
-func (agent *Agent) batchDispatch(ctx context.Context, kvList *badger.KVList) error {
-	logs := []*audit.Log{}
-
-	for _, item := range kvList.GetKv() {
-		log := &core.Log{}
+func (agent *Agent) sign(logs []*core.Log) ([]byte, error) {
+	if agent.SigningKey == nil {
+		return nil, nil
+	}
 
-		json.Unmarshal(item.GetValue(), log)
+	return core.SignLogs(agent.SigningKey, agent.Name, logs)
+}
 
-		logsAPILog, err := CoreLogToLogsAPILog(log)
+func (agent *Agent) dispatchBatch(ctx context.Context, kvs []*pb.KV) error {
+	logs := []*audit.Log{}
+	coreLogs := []*core.Log{}
+	keys := map[string][]byte{}
+	sent := []*pb.KV{}
+
+	for _, item := range kvs {
+		log, err := core.DecodeLog(item.GetValue())
+
+		if err == nil {
+			var logsAPILog *audit.Log
+			if logsAPILog, err = CoreLogToLogsAPILog(log); err == nil {
+				logsAPILog.AgentSequence, _ = keySequence(item.Key)
+				logs = append(logs, logsAPILog)
+				coreLogs = append(coreLogs, log)
+				keys[log.ID.String()] = item.Key
+				sent = append(sent, item)
+				continue
+			}
+		}
 
-		if err != nil {
+		if err = agent.deadLetterNow(item.Key, err.Error()); err != nil {
 			return err
 		}
+	}
 
-		logs = append(logs, logsAPILog)
+	if len(logs) == 0 {
+		return nil
 	}
 
-	reply, err := agent.collectorClient.BatchPersistLog(ctx, &audit.BatchPersistLogRequest{
-		Logs: logs,
+	signature, err := agent.sign(coreLogs)
+
+	if err != nil {
+		return err
+	}
+
+	var reply *audit.PersistLogsReply
+	err = agent.callCollector(ctx, func(ctx context.Context) error {
+		reply, err = agent.collectorClient.BatchPersistLog(ctx, &audit.BatchPersistLogRequest{
+			Logs:         logs,
+			AgentId:      agent.Name,
+			Signature:    signature,
+			AllOrNothing: agent.allOrNothing,
+		})
+		return err
 	})
 
+	// A single bad log fails the whole batch, sending the logs one by one
+	// finds out which, and rejects it as individual dispatch would.
+	if classify(err) == failureRejected {
+		return agent.simpleDispatch(ctx, sent)
+	}
+
 	if err != nil {
 		return err
 	}
 
+	retained := 0
 	for _, result := range reply.Results {
-		if result.GetSuccess() || (result.Reason.Code == core.ErrorCodeAlreadyPersistedLog) {
+		key, ok := keys[result.Id]
+
+		if !ok {
+			continue
+		}
+
+		switch {
+		case result.GetSuccess() || result.GetReason().GetCode() == core.ErrorCodeAlreadyPersistedLog:
 			fmt.Println("Persisted", result.Id)
-			err = agent.db.Update(func(txn *badger.Txn) error {
-				return txn.Delete([]byte(result.Id))
-			})
+			err = agent.remove(key)
+		case isRetained(result.GetReason().GetCode()):
+			retained++
+		default:
+			err = agent.reject(key, result.Reason.GetMessage(), result.Reason.GetCode())
+		}
 
-			if err != nil {
-				return err
-			}
+		if err != nil {
+			return err
 		}
 	}
 
+	// The flush stops here so that the logs kept are sent again with the
+	// next one.
+	if retained > 0 {
+		return fmt.Errorf("collector did not persist %d logs", retained)
+	}
+
 	return nil
 }
 
-func (agent *Agent) simpleDispatch(ctx context.Context, kvList *badger.KVList) error {
-	for _, item := range kvList.GetKv() {
-		fmt.Println("Consuming", string(item.Key), string(item.Value))
-		fmt.Println(agent.collectorAddress)
+// isRetained tells whether a log the collector did not persist for the
+// reason code stays in the buffer as is, neither rejected nor
+// dead-lettered, because the log itself is not at fault.
+func isRetained(code int32) bool {
+	return code == core.ErrorCodeStorageFailure || code == core.ErrorCodeBatchAborted
+}
 
-		reply, err := agent.collectorClient.PersistLog(ctx, &audit.PersistLogRequest{
-			Log: &audit.Log{
-				Id: string(item.Key),
-			},
-		})
+func (agent *Agent) simpleDispatch(ctx context.Context, kvs []*pb.KV) error {
+	for _, item := range kvs {
+		log, err := core.DecodeLog(item.GetValue())
 
 		if err != nil {
-			st, ok := status.FromError(err)
-
-			if ok {
-				switch st.Code() {
-				case codes.AlreadyExists:
-					fmt.Println("Persisted", string(item.Key))
-					err = agent.db.Update(func(txn *badger.Txn) error {
-						return txn.Delete(item.Key)
-					})
-
-					if err != nil {
-						return err
-					}
-
-					return nil
-				default:
-					return err
-				}
+			if err = agent.deadLetterNow(item.Key, err.Error()); err != nil {
+				return err
 			}
-
-			return err
+			continue
 		}
 
-		fmt.Println("Reply", reply)
-		if reply.GetSuccess() && reply.Id == string(item.Key) {
+		err = agent.callCollector(ctx, func(ctx context.Context) error {
+			return agent.dispatchLog(ctx, item.Key, log)
+		})
+
+		switch {
+		case err == nil:
 			fmt.Println("Persisted", string(item.Key))
-			err = agent.db.Update(func(txn *badger.Txn) error {
-				return txn.Delete(item.Key)
-			})
+			err = agent.remove(item.Key)
+		case classify(err) == failureRejected:
+			err = agent.reject(item.Key, status.Convert(err).Message(), rejectionCode(err))
+		}
 
-			if err != nil {
-				return err
-			}
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
+}
+
+// rejectionCode returns the code of the error the collector rejected a call
+// with, as given in the details of its status, or 0 when it gave none.
+func rejectionCode(err error) int32 {
+	for _, detail := range status.Convert(err).Details() {
+		if reason, ok := detail.(*audit.Error); ok {
+			return reason.GetCode()
+		}
+	}
 
+	return 0
 }
 
-func (agent *Agent) Start() error {
-	signal.Notify(agent.signals, syscall.SIGTERM)
-	defer agent.shutdown()
+// dispatchLog sends a single log buffered at key to the collector. A log the
+// collector already has counts as persisted.
+func (agent *Agent) dispatchLog(ctx context.Context, key []byte, log *core.Log) error {
+	logsAPILog, err := CoreLogToLogsAPILog(log)
 
-	var err error
+	if err != nil {
+		return err
+	}
 
-	agent.collectorClient, err = agent.newCollectorClient()
+	logsAPILog.AgentSequence, _ = keySequence(key)
+
+	signature, err := agent.sign([]*core.Log{log})
 
 	if err != nil {
 		return err
 	}
 
-	// Start a timer to flush the buffer every minute
-	ticker := time.NewTicker(5 * time.Second)
-	go func() {
-		for range ticker.C {
-			fmt.Println("flushing..")
-			agent.flushBuffer()
+	_, err = agent.collectorClient.PersistLog(ctx, &audit.PersistLogRequest{
+		Log:       logsAPILog,
+		AgentId:   agent.Name,
+		Signature: signature,
+	})
+
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+
+	return err
+}
+
+func (agent *Agent) streamDispatch(kvs []*pb.KV) error {
+	for _, item := range kvs {
+		log, err := core.DecodeLog(item.GetValue())
+
+		if err != nil {
+			if err = agent.deadLetterNow(item.Key, err.Error()); err != nil {
+				return err
+			}
+			continue
 		}
-	}()
+
+		if err = agent.logStream.send(item.Key, log); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Start opens the buffer, connects to the collector and flushes the buffer
+// every flush interval until ctx is done or Close is called.
+func (agent *Agent) Start(ctx context.Context) error {
+	var err error
 
 	// Check if a file already exists and if it needs to be flushed
 	// Create file if it does not exists
-	db, err := badger.Open(badger.DefaultOptions("/tmp/trail"))
+	key, err := LoadEncryptionKey(agent.encryption)
+
+	if err != nil {
+		return err
+	}
+
+	agent.db, err = badger.Open(bufferOptions(agent.bufferDir, key, agent.encryption.DataKeyRotation))
+
+	if err != nil {
+		return err
+	}
+
+	if agent.overflow == OverflowSpill {
+		agent.spill, err = badger.Open(bufferOptions(agent.spillDir, key, agent.encryption.DataKeyRotation))
+
+		if err != nil {
+			agent.db.Close()
+			return err
+		}
+	}
+
+	if agent.sequence, err = lastSequence(agent.db, agent.spill); err == nil {
+		err = agent.migrateLegacyKeys()
+	}
+
+	if err == nil {
+		err = agent.countBuffered()
+	}
 
 	if err != nil {
+		agent.closeBuffers()
 		return err
 	}
 
 	fmt.Println("Database OK")
 
-	agent.db = db
+	agent.collectorClient, err = agent.newCollectorClient()
+
+	if err != nil {
+		agent.closeBuffers()
+		return err
+	}
+
+	if agent.DispatchMode == DispatchModeStream {
+		agent.logStream = newLogStream(agent)
+	}
+
+	flushCtx, stopFlushing := context.WithCancel(ctx)
+	agent.stopFlushing = stopFlushing
+	agent.flushDone = make(chan struct{})
+
+	go agent.flushPeriodically(flushCtx)
 
-	fmt.Println("Starting Stream")
-	stream := db.NewStream()
-	fmt.Println("Stream Started")
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	agent.stopHeartbeat = stopHeartbeat
+	agent.heartbeatDone = make(chan struct{})
 
-	stream.Send = func(buf *z.Buffer) error {
+	go agent.heartbeatPeriodically(heartbeatCtx)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	return nil
+}
+
+func (agent *Agent) flushPeriodically(ctx context.Context) {
+	defer close(agent.flushDone)
+
+	ticker := time.NewTicker(agent.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fmt.Println("flushing..")
+			if err := agent.flushBuffer(ctx); err != nil {
+				fmt.Println("Flush failed:", err)
+			}
+		}
+	}
+}
+
+// Close stops the periodic flush and heartbeats, makes a last attempt at
+// flushing the buffer until ctx is done, tells the collector the agent is
+// stopping, then closes the collector connection and the buffer. Logs that
+// could not be flushed stay in the buffer for the next start.
+func (agent *Agent) Close(ctx context.Context) error {
+	if agent.db == nil {
+		return ErrAgentNotStarted
+	}
+
+	agent.stopFlushing()
+	<-agent.flushDone
+
+	agent.stopHeartbeat()
+	<-agent.heartbeatDone
+
+	fmt.Println("Flushing buffer before shutdown")
+	flushErr := agent.Flush(ctx)
+
+	// Acknowledgements still on their way delete from the buffer, which
+	// must stay open until they arrive.
+	var streamErr error
+	if agent.logStream != nil {
+		streamErr = agent.logStream.close(ctx)
+	}
+
+	// The last heartbeat reports what is left in the buffer.
+	if err := agent.heartbeat(ctx, true); err != nil {
+		fmt.Println("Failed to deregister from collector:", err)
+	}
+
+	return errors.Join(flushErr, streamErr, agent.collectorClientConn.Close(), agent.closeBuffers())
+}
+
+func (agent *Agent) closeBuffers() error {
+	if agent.spill == nil {
+		return agent.db.Close()
+	}
+
+	return errors.Join(agent.spill.Close(), agent.db.Close())
+}
+
+// Flush sends every buffered log to the collector, returning once the
+// buffer was read through or ctx is done.
+func (agent *Agent) Flush(ctx context.Context) error {
+	if agent.db == nil {
+		return ErrAgentNotStarted
+	}
+
+	return agent.flushBuffer(ctx)
+}
+
+// flushBuffer sends the buffered logs in the order they arrived, batch by
+// batch, and stops at the first batch that could not be sent so that no log
+// overtakes an earlier one of the same service. Only logs the collector
+// rejects are left behind.
+func (agent *Agent) flushBuffer(ctx context.Context) error {
+	// Flushes may be requested while a periodic one is running, and would
+	// send the same logs twice.
+	agent.flushMu.Lock()
+	defer agent.flushMu.Unlock()
+
+	var caughtUp func()
+	if agent.logStream != nil {
+		caughtUp = agent.logStream.catchingUp()
+	}
+
+	after := []byte(logPrefix)
+
+	for {
+		kvs, err := agent.readBuffer(after, agent.batchSize)
 
-		kvList, err := badger.BufferToKVList(buf)
 		if err != nil {
 			return err
 		}
 
-		if agent.DispatchMode == DispatchModeBatch {
-			return agent.batchDispatch(ctx, kvList)
+		if len(kvs) == 0 {
+			break
 		}
 
-		return agent.simpleDispatch(ctx, kvList)
+		if err = agent.dispatch(ctx, kvs); err != nil {
+			return err
+		}
+
+		after = kvs[len(kvs)-1].Key
 	}
 
-	agent.stream = stream
+	if caughtUp != nil {
+		caughtUp()
+	}
 
-	<-agent.signals
+	if agent.spill != nil {
+		return agent.unspill()
+	}
 
 	return nil
 }
 
-func (agent *Agent) flushBuffer() error {
-	// Read registers on file and send to remote gRPC API to save Audit Logs
-	// Open the buffer file for reading
-	if err := agent.stream.Orchestrate(context.Background()); err != nil {
-		return err
+func (agent *Agent) dispatch(ctx context.Context, kvs []*pb.KV) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	switch agent.DispatchMode {
+	case DispatchModeBatch:
+		return agent.dispatchBatch(ctx, kvs)
+	case DispatchModeStream:
+		return agent.streamDispatch(kvs)
+	default:
+		return agent.simpleDispatch(ctx, kvs)
 	}
-
-	return nil
 }
 
-func NewAgent(name string, applicationName string, collectorAddress string) *Agent {
+// NewAgent creates an agent from a configuration, which must be valid.
+func NewAgent(config *Config) *Agent {
+	dispatchMode, _ := ParseDispatchMode(config.Dispatch.Mode)
+	overflow, _ := ParseOverflowPolicy(config.Buffer.Overflow)
+
 	agent := &Agent{
-		Name:             name,
-		signals:          make(chan os.Signal, 1),
-		collectorAddress: collectorAddress,
-		DispatchMode:     DispatchModeBatch,
+		Name:               config.Name,
+		collectorEndpoints: config.Collector.Endpoints,
+		CollectorTLS:       &config.Collector.TLS,
+		DispatchMode:       dispatchMode,
+		bufferDir:          config.Buffer.Dir,
+		flushInterval:      config.Dispatch.FlushInterval,
+		batchSize:          config.Dispatch.BatchSize,
+		allOrNothing:       config.Dispatch.AllOrNothing,
+		retry: retryPolicy{
+			maxAttempts:    config.Retry.MaxAttempts,
+			initialBackoff: config.Retry.InitialBackoff,
+			maxBackoff:     config.Retry.MaxBackoff,
+		},
+		breaker: &circuitBreaker{
+			failureThreshold: config.CircuitBreaker.FailureThreshold,
+			cooldown:         config.CircuitBreaker.Cooldown,
+		},
+		maxRejections:     config.DeadLetter.MaxRejections,
+		heartbeatInterval: config.Heartbeat.Interval,
+		maxRecords:        config.Buffer.MaxRecords,
+		maxBytes:          config.Buffer.MaxBytes,
+		overflow:          overflow,
+		spillDir:          config.Buffer.SpillDir,
+		encryption:        config.Buffer.Encryption,
 		Application: Application{
-			Name:          applicationName,
-			Version:       "1.0.0",
+			Name:          config.Application,
+			Version:       config.ApplicationVersion,
 			InitializedAt: time.Now(),
 		},
 	}
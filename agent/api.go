@@ -2,64 +2,153 @@ package agent
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"net"
 	"oversee/core"
+	"oversee/pkg/lifecycle"
+	"oversee/pkg/tlsconfig"
+	"time"
 
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// ClientIdentityMetadataKey is the metadata key under which the agent
+// records the identity of a client that authenticated with a certificate.
+// Clients may not set it themselves.
+const ClientIdentityMetadataKey = "oversee_client_identity"
+
 type IngestionAPI struct {
 	UnimplementedAgentServer
-	agent *Agent
+	agent         *Agent
+	listenAddress string
+	tls           *tlsconfig.Config
+	limits        LimitsConfig
+	// shutdownTimeout bounds both draining RPCs and the final flush.
+	shutdownTimeout time.Duration
 }
 
-// Log implements AgentServer.
+// Log implements AgentServer. The identity of a client that authenticated
+// with a certificate is recorded in the metadata of its logs.
 func (a IngestionAPI) Log(ctx context.Context, request *LogRequest) (*LogReply, error) {
-	err := a.agent.Log(&core.Log{
-		ID:                uuid.New(),
+	metadata, err := metadataFromRequest(request.Metadata, a.limits)
+
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if _, ok := metadata[ClientIdentityMetadataKey]; ok {
+		return nil, status.Errorf(codes.InvalidArgument, "metadata %s is set by the agent", ClientIdentityMetadataKey)
+	}
+
+	if identity, ok := tlsconfig.PeerIdentity(ctx); ok {
+		metadata[ClientIdentityMetadataKey] = identity
+	}
+
+	// Clients send the same ID on every retry of an event, so that the
+	// agent stores it once.
+	id := uuid.New()
+	if request.Id != "" {
+		if id, err = uuid.Parse(request.Id); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid log id: %v", err)
+		}
+	}
+
+	log := &core.Log{
+		ID:                id,
 		Timestamp:         request.Timestamp.AsTime(),
 		ServiceName:       request.ServiceName,
 		Operation:         request.Operation,
 		ActorId:           request.ActorId,
 		ActorType:         request.ActorType,
 		AffectedResources: request.AffectedResources,
-		Metadata:          map[string]any{},
-		IntegrityHash:     "",
-	})
+		Metadata:          metadata,
+		IntegrityHash:     request.IntegrityHash,
+	}
+
+	// Metadata that cannot be encoded, such as NaN numbers, is the caller's
+	// fault rather than the buffer's.
+	if _, err = log.Canonical(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = a.agent.Log(ctx, log)
+
+	if errors.Is(err, ErrBufferFull) {
+		st, detailsErr := status.New(codes.ResourceExhausted, err.Error()).WithDetails(a.agent.BufferStatus())
+
+		if detailsErr != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		return nil, st.Err()
+	}
 
 	return &LogReply{
 		Success: err == nil,
+		Buffer:  a.agent.BufferStatus(),
 	}, err
 }
 
-func (a *IngestionAPI) Serve() error {
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", 4092))
+// Serve starts the agent and serves its API until ctx is done, then drains
+// in-flight RPCs and flushes the buffer one last time.
+func (a *IngestionAPI) Serve(ctx context.Context) error {
+	creds, err := a.tls.ServerCredentials()
 
 	if err != nil {
 		return err
 	}
 
-	s := grpc.NewServer()
+	listener, err := net.Listen("tcp", a.listenAddress)
+
+	if err != nil {
+		return err
+	}
+
+	if err = a.agent.Start(ctx); err != nil {
+		listener.Close()
+		return err
+	}
+
+	s := grpc.NewServer(grpc.Creds(creds))
 
 	RegisterAgentServer(s, a)
+
+	serveErr := make(chan error, 1)
 	go func() {
-		err = s.Serve(listener)
-		if err != nil {
-			fmt.Println(err)
-		}
+		serveErr <- s.Serve(listener)
 	}()
 
-	a.agent.Start()
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+	}
+
+	fmt.Println("Shutting down agent")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	lifecycle.StopGRPCServer(shutdownCtx, s)
 
-	return nil
+	return errors.Join(err, a.agent.Close(shutdownCtx))
 }
 
-func NewIngestionAPI(collectorAddress string) *IngestionAPI {
-	agent := NewAgent("main", "demo", collectorAddress)
+// NewIngestionAPI creates the agent API from a configuration, which must be
+// valid. Logs are sent unsigned when signingKey is nil.
+func NewIngestionAPI(config *Config, signingKey ed25519.PrivateKey) *IngestionAPI {
+	agent := NewAgent(config)
+	agent.SigningKey = signingKey
 
 	return &IngestionAPI{
-		agent: agent,
+		agent:           agent,
+		listenAddress:   config.ListenAddress,
+		tls:             &config.TLS,
+		limits:          config.Limits,
+		shutdownTimeout: config.ShutdownTimeout,
 	}
 }
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"oversee/core"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// peerContext returns the context of a call from a client that
+// authenticated with a certificate for identity.
+func peerContext(identity string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: identity}}
+
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		},
+	})
+}
+
+func newTestIngestionAPI(t *testing.T) *IngestionAPI {
+	t.Helper()
+
+	a, err := New(
+		WithName("api-test"),
+		WithBufferDir(t.TempDir()),
+		WithCollector("127.0.0.1:1"),
+		WithFlushInterval(time.Hour),
+		WithHeartbeatInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close(context.Background()) })
+
+	return &IngestionAPI{agent: a, limits: DefaultConfig().Limits}
+}
+
+func newTestLogRequest(t *testing.T, metadata map[string]any) *LogRequest {
+	t.Helper()
+
+	fields, err := structpb.NewStruct(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &LogRequest{
+		Id:          uuid.NewString(),
+		Timestamp:   timestamppb.Now(),
+		ServiceName: "billing",
+		Operation:   "invoice.pay",
+		ActorId:     "user-1",
+		ActorType:   "user",
+		Metadata:    fields,
+	}
+}
+
+// bufferedLog returns the log buffered under the ID.
+func bufferedLog(t *testing.T, a *Agent, id string) *core.Log {
+	t.Helper()
+
+	kvs, err := a.readBuffer(nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kv := range kvs {
+		log, err := core.DecodeLog(kv.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if log.ID.String() == id {
+			return log
+		}
+	}
+
+	t.Fatalf("log %s is not buffered", id)
+	return nil
+}
+
+func TestLogRecordsClientIdentity(t *testing.T) {
+	api := newTestIngestionAPI(t)
+
+	identified := newTestLogRequest(t, map[string]any{"amount": 12.5})
+	if _, err := api.Log(peerContext("billing-app"), identified); err != nil {
+		t.Fatal(err)
+	}
+
+	log := bufferedLog(t, api.agent, identified.Id)
+	if identity := log.Metadata[ClientIdentityMetadataKey]; identity != "billing-app" {
+		t.Errorf("the log of an identified client has identity %v, want %q", identity, "billing-app")
+	}
+
+	anonymous := newTestLogRequest(t, map[string]any{"amount": 12.5})
+	if _, err := api.Log(context.Background(), anonymous); err != nil {
+		t.Fatal(err)
+	}
+
+	log = bufferedLog(t, api.agent, anonymous.Id)
+	if identity, ok := log.Metadata[ClientIdentityMetadataKey]; ok {
+		t.Errorf("the log of a client without certificate has identity %v", identity)
+	}
+}
+
+func TestLogRejectsClaimedClientIdentity(t *testing.T) {
+	api := newTestIngestionAPI(t)
+
+	for _, ctx := range []context.Context{context.Background(), peerContext("billing-app")} {
+		request := newTestLogRequest(t, map[string]any{ClientIdentityMetadataKey: "billing-app"})
+
+		if _, err := api.Log(ctx, request); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Log() = %v for a client setting its identity, want code %v", err, codes.InvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+)
+
+// Buffered logs are keyed by the sequence number the agent gives them on
+// arrival, zero padded so that keys sort in arrival order, then by their ID.
+// The last sequence number given is kept under sequenceKey so that numbers
+// keep growing across restarts, which lets the collector tell from the
+// numbers sent along with logs whether any went missing.
+const (
+	logPrefix   = "log/"
+	sequenceKey = "meta/sequence"
+)
+
+// The IDs of stored logs are remembered under storedPrefix for
+// storedWindow, so that a log retried by a client that missed the reply is
+// not buffered twice.
+const (
+	storedPrefix = "stored/"
+	storedWindow = 24 * time.Hour
+)
+
+func storedKey(id string) []byte {
+	return []byte(storedPrefix + id)
+}
+
+func bufferKey(sequence uint64, id string) []byte {
+	return fmt.Appendf(nil, "%s%020d/%s", logPrefix, sequence, id)
+}
+
+// keySequence returns the sequence number of a buffer key.
+func keySequence(key []byte) (uint64, bool) {
+	rest, ok := strings.CutPrefix(string(key), logPrefix)
+	if !ok || len(rest) < 20 {
+		return 0, false
+	}
+
+	sequence, err := strconv.ParseUint(rest[:20], 10, 64)
+	return sequence, err == nil
+}
+
+func isBufferedLogKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(logPrefix))
+}
+
+func encodeSequence(sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, sequence)
+}
+
+// lastSequence returns the highest sequence number given out by the agent
+// owning db and spill, the latter being nil without spill directory.
+func lastSequence(db *badger.DB, spill *badger.DB) (uint64, error) {
+	var last uint64
+
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(sequenceKey))
+
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(value []byte) error {
+			if len(value) != 8 {
+				return fmt.Errorf("invalid buffer sequence %x", value)
+			}
+
+			last = binary.BigEndian.Uint64(value)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Logs spilled right before a crash may be ahead of the stored number.
+	for _, d := range []*badger.DB{db, spill} {
+		if d == nil {
+			continue
+		}
+
+		err = d.View(func(txn *badger.Txn) error {
+			options := badger.DefaultIteratorOptions
+			options.PrefetchValues = false
+			options.Reverse = true
+			options.Prefix = []byte(logPrefix)
+			it := txn.NewIterator(options)
+			defer it.Close()
+
+			it.Seek([]byte(logPrefix + "\xff"))
+			if it.Valid() {
+				if sequence, ok := keySequence(it.Item().Key()); ok {
+					last = max(last, sequence)
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return last, nil
+}
+
+// migrateLegacyKeys moves logs buffered under their bare ID by earlier
+// versions of the agent to sequence keys, in the order they were written.
+func (agent *Agent) migrateLegacyKeys() error {
+	type legacyLog struct {
+		key     []byte
+		version uint64
+	}
+
+	legacy := []legacyLog{}
+
+	err := agent.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := it.Item().Key()
+			if !bytes.HasPrefix(key, []byte(logPrefix)) && !bytes.Contains(key, []byte("/")) {
+				legacy = append(legacy, legacyLog{key: it.Item().KeyCopy(nil), version: it.Item().Version()})
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil || len(legacy) == 0 {
+		return err
+	}
+
+	slices.SortFunc(legacy, func(a, b legacyLog) int {
+		return cmp.Compare(a.version, b.version)
+	})
+
+	for _, log := range legacy {
+		err = agent.db.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(log.key)
+
+			if err != nil {
+				return err
+			}
+
+			value, err := item.ValueCopy(nil)
+
+			if err != nil {
+				return err
+			}
+
+			agent.sequence++
+
+			if err = txn.Set(bufferKey(agent.sequence, string(log.key)), value); err != nil {
+				return err
+			}
+
+			if err = txn.Set([]byte(sequenceKey), encodeSequence(agent.sequence)); err != nil {
+				return err
+			}
+
+			if err = txn.Delete(rejectionsKey(log.key)); err != nil {
+				return err
+			}
+
+			return txn.Delete(log.key)
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to migrate buffered log %s: %w", log.key, err)
+		}
+	}
+
+	fmt.Println("Migrated", len(legacy), "buffered logs to ordered keys")
+	return nil
+}
+
+// readBuffer returns up to limit buffered logs with keys after after, in
+// arrival order.
+func (agent *Agent) readBuffer(after []byte, limit int) ([]*pb.KV, error) {
+	kvs := []*pb.KV{}
+
+	err := agent.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte(logPrefix)
+		options.PrefetchSize = limit
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Seek(after); it.Valid() && len(kvs) < limit; it.Next() {
+			item := it.Item()
+
+			if bytes.Equal(item.Key(), after) {
+				continue
+			}
+
+			value, err := item.ValueCopy(nil)
+
+			if err != nil {
+				return err
+			}
+
+			kvs = append(kvs, &pb.KV{Key: item.KeyCopy(nil), Value: value})
+		}
+
+		return nil
+	})
+
+	return kvs, err
+}
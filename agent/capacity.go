@@ -0,0 +1,299 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"oversee/core"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+var ErrBufferFull = errors.New("agent buffer is full")
+
+// errAlreadyStored is returned by store for a log whose ID was stored
+// within storedWindow.
+var errAlreadyStored = errors.New("log is already stored")
+
+// OverflowPolicy is what the agent does with a new log when the buffer is
+// full.
+type OverflowPolicy int
+
+const (
+	OverflowReject OverflowPolicy = iota + 1
+	OverflowDropOldest
+	// OverflowSpill writes logs to a secondary buffer, from which they move
+	// back to the buffer as flushes make room.
+	OverflowSpill
+)
+
+func ParseOverflowPolicy(policy string) (OverflowPolicy, error) {
+	switch policy {
+	case "reject":
+		return OverflowReject, nil
+	case "drop-oldest":
+		return OverflowDropOldest, nil
+	case "spill":
+		return OverflowSpill, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy %q", policy)
+	}
+}
+
+// BufferStatus returns the fill level of the buffer.
+func (agent *Agent) BufferStatus() *BufferStatus {
+	agent.usageMu.Lock()
+	defer agent.usageMu.Unlock()
+
+	return &BufferStatus{
+		Records:        agent.records,
+		Bytes:          agent.bytes,
+		MaxRecords:     agent.maxRecords,
+		MaxBytes:       agent.maxBytes,
+		SpilledRecords: agent.spilled,
+	}
+}
+
+// full reports whether a log of size bytes would not fit in the buffer.
+// Callers hold usageMu.
+func (agent *Agent) full(size int) bool {
+	return (agent.maxRecords > 0 && agent.records+1 > agent.maxRecords) ||
+		(agent.maxBytes > 0 && agent.bytes+int64(size) > agent.maxBytes)
+}
+
+// store writes a log to the buffer under the next sequence number,
+// applying the overflow policy when the buffer is full, and queues it for
+// the live stream in the same order. It returns whether the log was
+// spilled instead.
+func (agent *Agent) store(log *core.Log, value []byte) (bool, error) {
+	agent.usageMu.Lock()
+	defer agent.usageMu.Unlock()
+
+	id := log.ID.String()
+
+	err := agent.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(storedKey(id))
+		return err
+	})
+
+	if err == nil {
+		return false, errAlreadyStored
+	}
+
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return false, err
+	}
+
+	// A log that would not fit even in an empty buffer is rejected before
+	// any other log is dropped or spilled for it.
+	if agent.maxBytes > 0 && int64(len(value)) > agent.maxBytes {
+		return false, ErrBufferFull
+	}
+
+	// Once logs were spilled, new ones follow them so that they are sent in
+	// the order they came.
+	spill := agent.overflow == OverflowSpill && agent.spilled > 0
+
+	for !spill && agent.full(len(value)) {
+		switch agent.overflow {
+		case OverflowDropOldest:
+			if agent.records == 0 {
+				return false, ErrBufferFull
+			}
+
+			if err := agent.dropOldest(); err != nil {
+				return false, err
+			}
+		case OverflowSpill:
+			spill = true
+		default:
+			return false, ErrBufferFull
+		}
+	}
+
+	sequence := agent.sequence + 1
+	key := bufferKey(sequence, id)
+
+	if spill {
+		err := agent.spill.Update(func(txn *badger.Txn) error {
+			return txn.Set(key, value)
+		})
+
+		if err != nil {
+			return false, err
+		}
+
+		agent.spilled++
+	}
+
+	err = agent.db.Update(func(txn *badger.Txn) error {
+		if !spill {
+			if err := txn.Set(key, value); err != nil {
+				return err
+			}
+		}
+
+		if err := txn.SetEntry(badger.NewEntry(storedKey(id), nil).WithTTL(storedWindow)); err != nil {
+			return err
+		}
+
+		return txn.Set([]byte(sequenceKey), encodeSequence(sequence))
+	})
+
+	// A spilled log is already stored, and the next start takes its
+	// sequence number into account.
+	if err != nil && !spill {
+		return false, err
+	}
+
+	agent.sequence = sequence
+
+	if !spill {
+		agent.records++
+		agent.bytes += int64(len(value))
+
+		if agent.logStream != nil {
+			agent.logStream.queueLive(key, log)
+		}
+	}
+
+	return spill, nil
+}
+
+// dropOldest deletes the log that arrived first in the buffer. Callers hold
+// usageMu.
+func (agent *Agent) dropOldest() error {
+	return agent.db.Update(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = []byte(logPrefix)
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		it.Rewind()
+		if !it.Valid() {
+			return ErrBufferFull
+		}
+
+		key := it.Item().KeyCopy(nil)
+		size := it.Item().ValueSize()
+
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+
+		if err := txn.Delete(rejectionsKey(key)); err != nil {
+			return err
+		}
+
+		fmt.Println("Buffer full, dropped", string(key))
+		agent.records--
+		agent.bytes -= size
+
+		return nil
+	})
+}
+
+// released accounts for a log of size bytes leaving the buffer.
+func (agent *Agent) released(size int64) {
+	agent.usageMu.Lock()
+	defer agent.usageMu.Unlock()
+
+	agent.records--
+	agent.bytes -= size
+}
+
+// countBuffered sets the fill level from the buffers' content.
+func (agent *Agent) countBuffered() error {
+	err := agent.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = []byte(logPrefix)
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			agent.records++
+			agent.bytes += it.Item().ValueSize()
+		}
+
+		return nil
+	})
+
+	if err != nil || agent.spill == nil {
+		return err
+	}
+
+	return agent.spill.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			agent.spilled++
+		}
+
+		return nil
+	})
+}
+
+// unspill moves spilled logs back to the buffer, oldest first, as long as
+// they fit.
+func (agent *Agent) unspill() error {
+	agent.usageMu.Lock()
+	defer agent.usageMu.Unlock()
+
+	for agent.spilled > 0 {
+		var key, value []byte
+
+		err := agent.spill.View(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+
+			it.Rewind()
+			if !it.Valid() {
+				return nil
+			}
+
+			key = it.Item().KeyCopy(nil)
+			var err error
+			value, err = it.Item().ValueCopy(nil)
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if key == nil {
+			agent.spilled = 0
+			return nil
+		}
+
+		if agent.full(len(value)) {
+			return nil
+		}
+
+		err = agent.db.Update(func(txn *badger.Txn) error {
+			return txn.Set(key, value)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = agent.spill.Update(func(txn *badger.Txn) error {
+			return txn.Delete(key)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		agent.spilled--
+		agent.records++
+		agent.bytes += int64(len(value))
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"oversee/core"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newCapacityTestAgent returns an agent whose buffer holds up to maxBytes,
+// with the overflow policy, and which never flushes on its own.
+func newCapacityTestAgent(t *testing.T, maxBytes int64, overflow string) *Agent {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.Buffer.MaxBytes = maxBytes
+	config.Buffer.Overflow = overflow
+	config.Buffer.SpillDir = t.TempDir()
+
+	a, err := New(
+		WithConfig(config),
+		WithName("capacity-test"),
+		WithBufferDir(t.TempDir()),
+		WithCollector("127.0.0.1:1"),
+		WithFlushInterval(time.Hour),
+		WithHeartbeatInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close(context.Background()) })
+
+	return a
+}
+
+func newCapacityTestLog(note string) *core.Log {
+	return &core.Log{
+		ID:                uuid.New(),
+		Timestamp:         time.Now().UTC(),
+		ServiceName:       "billing",
+		Operation:         "invoice.pay",
+		ActorId:           "user-1",
+		ActorType:         "user",
+		AffectedResources: []string{"invoice/1"},
+		Metadata:          map[string]any{"note": note},
+	}
+}
+
+func TestOversizedLogIsRejectedWithoutDroppingOthers(t *testing.T) {
+	for _, overflow := range []string{"reject", "drop-oldest", "spill"} {
+		t.Run(overflow, func(t *testing.T) {
+			a := newCapacityTestAgent(t, 4096, overflow)
+			ctx := context.Background()
+
+			for range 3 {
+				if err := a.Log(ctx, newCapacityTestLog("paid")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			before := a.BufferStatus()
+
+			err := a.Log(ctx, newCapacityTestLog(strings.Repeat("x", 8192)))
+			if !errors.Is(err, ErrBufferFull) {
+				t.Errorf("logging more than the buffer holds returned %v, want %v", err, ErrBufferFull)
+			}
+
+			after := a.BufferStatus()
+			if after.Records != before.Records || after.Bytes != before.Bytes || after.SpilledRecords != 0 {
+				t.Errorf("the buffer went from %+v to %+v for a rejected log", before, after)
+			}
+		})
+	}
+}
+
+func TestDropOldestMakesRoomForNewLogs(t *testing.T) {
+	a := newCapacityTestAgent(t, 4096, "drop-oldest")
+	ctx := context.Background()
+
+	for range 100 {
+		if err := a.Log(ctx, newCapacityTestLog("paid")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status := a.BufferStatus()
+	if status.Bytes > status.MaxBytes || status.Records == 0 || status.Records == 100 {
+		t.Errorf("the buffer is at %+v after overflowing it", status)
+	}
+}
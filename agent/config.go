@@ -0,0 +1,441 @@
+package agent
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"oversee/pkg/tlsconfig"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of an agent. It is built from defaults, then
+// an optional YAML file, then OVERSEE_AGENT_* environment variables, then
+// command line flags, each overriding the previous one.
+type Config struct {
+	Name               string           `yaml:"name"`
+	Application        string           `yaml:"application"`
+	ApplicationVersion string           `yaml:"application_version"`
+	ListenAddress      string           `yaml:"listen_address"`
+	TLS                tlsconfig.Config `yaml:"tls"`
+	SigningKey         string           `yaml:"signing_key"`
+	Collector          CollectorConfig  `yaml:"collector"`
+	Buffer             BufferConfig     `yaml:"buffer"`
+	Dispatch           DispatchConfig   `yaml:"dispatch"`
+	Limits             LimitsConfig     `yaml:"limits"`
+	Retry              RetryConfig      `yaml:"retry"`
+	CircuitBreaker     BreakerConfig    `yaml:"circuit_breaker"`
+	DeadLetter         DeadLetterConfig `yaml:"dead_letter"`
+	Heartbeat          HeartbeatConfig  `yaml:"heartbeat"`
+	// ShutdownTimeout bounds draining in-flight requests and the final
+	// flush of the buffer on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type CollectorConfig struct {
+	// Endpoints are tried in order, the next one being used when the
+	// current one becomes unreachable.
+	Endpoints []string         `yaml:"endpoints"`
+	TLS       tlsconfig.Config `yaml:"tls"`
+}
+
+type BufferConfig struct {
+	Dir string `yaml:"dir"`
+	// MaxRecords and MaxBytes bound the buffered logs, 0 meaning no limit.
+	// Logs are counted by the size of their encoding.
+	MaxRecords int64 `yaml:"max_records"`
+	MaxBytes   int64 `yaml:"max_bytes"`
+	// Overflow is what happens to new logs when the buffer is full:
+	// reject, drop-oldest or spill to SpillDir.
+	Overflow   string           `yaml:"overflow"`
+	SpillDir   string           `yaml:"spill_dir"`
+	Encryption EncryptionConfig `yaml:"encryption"`
+}
+
+// EncryptionConfig encrypts the buffer and the spill directory at rest. They
+// are stored in plaintext when no key is given.
+type EncryptionConfig struct {
+	// KeyFile holds a hex encoded AES key of 16, 24 or 32 bytes.
+	KeyFile string `yaml:"key_file"`
+	// Key is the hex encoded key itself. It is only taken from the
+	// environment so that it is never printed.
+	Key string `yaml:"-"`
+	// DataKeyRotation is how often Badger replaces the data keys it
+	// encrypts with the key.
+	DataKeyRotation time.Duration `yaml:"data_key_rotation"`
+}
+
+type DispatchConfig struct {
+	Mode          string        `yaml:"mode"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	BatchSize     int           `yaml:"batch_size"`
+	// AllOrNothing asks the collector to persist no log of a batch when one
+	// of them is invalid.
+	AllOrNothing bool `yaml:"all_or_nothing"`
+}
+
+// LimitsConfig bounds what the agent API accepts in a single Log request.
+type LimitsConfig struct {
+	MaxMetadataBytes int `yaml:"max_metadata_bytes"`
+	MaxMetadataDepth int `yaml:"max_metadata_depth"`
+}
+
+// RetryConfig sets how collector calls failing for transient reasons are
+// retried, waiting a random time up to a backoff doubling from
+// InitialBackoff to MaxBackoff between attempts.
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// BreakerConfig sets when the agent stops calling a collector that keeps
+// failing, and for how long.
+type BreakerConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"`
+	Cooldown         time.Duration `yaml:"cooldown"`
+}
+
+type DeadLetterConfig struct {
+	// MaxRejections is how many times the collector may reject a log before
+	// it is set aside in the dead letters.
+	MaxRejections int `yaml:"max_rejections"`
+}
+
+// HeartbeatConfig sets how often the agent reports itself to the collector's
+// agent registry.
+type HeartbeatConfig struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Name:               "main",
+		Application:        "demo",
+		ApplicationVersion: "1.0.0",
+		ListenAddress:      ":4092",
+		SigningKey:         "agent.key",
+		Collector: CollectorConfig{
+			Endpoints: []string{"localhost:4093"},
+		},
+		Buffer: BufferConfig{
+			Dir:      "/tmp/trail",
+			Overflow: "reject",
+			Encryption: EncryptionConfig{
+				DataKeyRotation: 10 * 24 * time.Hour,
+			},
+		},
+		Dispatch: DispatchConfig{
+			Mode:          "batch",
+			FlushInterval: 5 * time.Second,
+			BatchSize:     500,
+		},
+		Limits: LimitsConfig{
+			MaxMetadataBytes: 64 * 1024,
+			MaxMetadataDepth: 8,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     2 * time.Second,
+		},
+		CircuitBreaker: BreakerConfig{
+			FailureThreshold: 5,
+			Cooldown:         30 * time.Second,
+		},
+		DeadLetter: DeadLetterConfig{
+			MaxRejections: 3,
+		},
+		Heartbeat: HeartbeatConfig{
+			Interval: 30 * time.Second,
+		},
+		ShutdownTimeout: 10 * time.Second,
+	}
+}
+
+// LoadConfig builds a configuration from the defaults, the YAML file at path
+// when it is not empty and the environment.
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+
+		decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+		decoder.KnownFields(true)
+
+		if err = decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	if err := config.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	stringVars := map[string]*string{
+		"OVERSEE_AGENT_NAME":                       &c.Name,
+		"OVERSEE_AGENT_APPLICATION":                &c.Application,
+		"OVERSEE_AGENT_APPLICATION_VERSION":        &c.ApplicationVersion,
+		"OVERSEE_AGENT_LISTEN_ADDRESS":             &c.ListenAddress,
+		"OVERSEE_AGENT_SIGNING_KEY":                &c.SigningKey,
+		"OVERSEE_AGENT_TLS_CERT":                   &c.TLS.CertFile,
+		"OVERSEE_AGENT_TLS_KEY":                    &c.TLS.KeyFile,
+		"OVERSEE_AGENT_TLS_CA":                     &c.TLS.CAFile,
+		"OVERSEE_AGENT_COLLECTOR_TLS_CERT":         &c.Collector.TLS.CertFile,
+		"OVERSEE_AGENT_COLLECTOR_TLS_KEY":          &c.Collector.TLS.KeyFile,
+		"OVERSEE_AGENT_COLLECTOR_TLS_CA":           &c.Collector.TLS.CAFile,
+		"OVERSEE_AGENT_COLLECTOR_TLS_SERVER_NAME":  &c.Collector.TLS.ServerName,
+		"OVERSEE_AGENT_BUFFER_DIR":                 &c.Buffer.Dir,
+		"OVERSEE_AGENT_DISPATCH_MODE":              &c.Dispatch.Mode,
+		"OVERSEE_AGENT_BUFFER_OVERFLOW":            &c.Buffer.Overflow,
+		"OVERSEE_AGENT_BUFFER_SPILL_DIR":           &c.Buffer.SpillDir,
+		"OVERSEE_AGENT_BUFFER_ENCRYPTION_KEY":      &c.Buffer.Encryption.Key,
+		"OVERSEE_AGENT_BUFFER_ENCRYPTION_KEY_FILE": &c.Buffer.Encryption.KeyFile,
+	}
+
+	for name, target := range stringVars {
+		if value, ok := lookup(name); ok {
+			*target = value
+		}
+	}
+
+	if value, ok := lookup("OVERSEE_AGENT_COLLECTOR_ENDPOINTS"); ok {
+		c.Collector.Endpoints = strings.Split(value, ",")
+	}
+
+	if value, ok := lookup("OVERSEE_AGENT_TLS_CLIENT_AUTH"); ok {
+		clientAuth, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid OVERSEE_AGENT_TLS_CLIENT_AUTH: %w", err)
+		}
+		c.TLS.RequireClientCert = clientAuth
+	}
+
+	if value, ok := lookup("OVERSEE_AGENT_DISPATCH_ALL_OR_NOTHING"); ok {
+		allOrNothing, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid OVERSEE_AGENT_DISPATCH_ALL_OR_NOTHING: %w", err)
+		}
+		c.Dispatch.AllOrNothing = allOrNothing
+	}
+
+	durationVars := map[string]*time.Duration{
+		"OVERSEE_AGENT_FLUSH_INTERVAL":           &c.Dispatch.FlushInterval,
+		"OVERSEE_AGENT_SHUTDOWN_TIMEOUT":         &c.ShutdownTimeout,
+		"OVERSEE_AGENT_RETRY_INITIAL_BACKOFF":    &c.Retry.InitialBackoff,
+		"OVERSEE_AGENT_RETRY_MAX_BACKOFF":        &c.Retry.MaxBackoff,
+		"OVERSEE_AGENT_CIRCUIT_BREAKER_COOLDOWN": &c.CircuitBreaker.Cooldown,
+		"OVERSEE_AGENT_BUFFER_DATA_KEY_ROTATION": &c.Buffer.Encryption.DataKeyRotation,
+		"OVERSEE_AGENT_HEARTBEAT_INTERVAL":       &c.Heartbeat.Interval,
+	}
+
+	for name, target := range durationVars {
+		if value, ok := lookup(name); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = d
+		}
+	}
+
+	intVars := map[string]*int{
+		"OVERSEE_AGENT_BATCH_SIZE":                 &c.Dispatch.BatchSize,
+		"OVERSEE_AGENT_MAX_METADATA_BYTES":         &c.Limits.MaxMetadataBytes,
+		"OVERSEE_AGENT_MAX_METADATA_DEPTH":         &c.Limits.MaxMetadataDepth,
+		"OVERSEE_AGENT_RETRY_MAX_ATTEMPTS":         &c.Retry.MaxAttempts,
+		"OVERSEE_AGENT_CIRCUIT_BREAKER_THRESHOLD":  &c.CircuitBreaker.FailureThreshold,
+		"OVERSEE_AGENT_DEAD_LETTER_MAX_REJECTIONS": &c.DeadLetter.MaxRejections,
+	}
+
+	for name, target := range intVars {
+		if value, ok := lookup(name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = n
+		}
+	}
+
+	int64Vars := map[string]*int64{
+		"OVERSEE_AGENT_BUFFER_MAX_RECORDS": &c.Buffer.MaxRecords,
+		"OVERSEE_AGENT_BUFFER_MAX_BYTES":   &c.Buffer.MaxBytes,
+	}
+
+	for name, target := range int64Vars {
+		if value, ok := lookup(name); ok {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = n
+		}
+	}
+
+	return nil
+}
+
+// endpointsFlag binds a comma separated flag to a list of endpoints.
+type endpointsFlag struct {
+	endpoints *[]string
+}
+
+func (f endpointsFlag) String() string {
+	if f.endpoints == nil {
+		return ""
+	}
+	return strings.Join(*f.endpoints, ",")
+}
+
+func (f endpointsFlag) Set(value string) error {
+	*f.endpoints = strings.Split(value, ",")
+	return nil
+}
+
+// RegisterFlags binds the configuration to command line flags, using the
+// current values as defaults.
+func (c *Config) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.Name, "name", c.Name, "name identifying the agent to the collector")
+	flags.StringVar(&c.Application, "application", c.Application, "name of the application the agent serves")
+	flags.StringVar(&c.ApplicationVersion, "application-version", c.ApplicationVersion, "version of the application the agent serves")
+	flags.StringVar(&c.ListenAddress, "listen", c.ListenAddress, "address the agent API listens on")
+	flags.StringVar(&c.SigningKey, "signing-key", c.SigningKey, "path to the agent's ed25519 signing key, created when missing")
+	flags.Var(endpointsFlag{&c.Collector.Endpoints}, "collector", "comma separated collector addresses, tried in order")
+	flags.StringVar(&c.Buffer.Dir, "buffer-dir", c.Buffer.Dir, "directory of the agent's local buffer")
+	flags.Int64Var(&c.Buffer.MaxRecords, "buffer-max-records", c.Buffer.MaxRecords, "most logs kept in the buffer, 0 for no limit")
+	flags.Int64Var(&c.Buffer.MaxBytes, "buffer-max-bytes", c.Buffer.MaxBytes, "most bytes of logs kept in the buffer, 0 for no limit")
+	flags.StringVar(&c.Buffer.Overflow, "buffer-overflow", c.Buffer.Overflow, "what happens to new logs when the buffer is full: reject, drop-oldest or spill")
+	flags.StringVar(&c.Buffer.SpillDir, "buffer-spill-dir", c.Buffer.SpillDir, "directory logs spill to when the buffer is full")
+	flags.StringVar(&c.Buffer.Encryption.KeyFile, "buffer-encryption-key-file", c.Buffer.Encryption.KeyFile, "path to the hex encoded key encrypting the buffer, which is plaintext without one")
+	flags.DurationVar(&c.Buffer.Encryption.DataKeyRotation, "buffer-data-key-rotation", c.Buffer.Encryption.DataKeyRotation, "interval between rotations of the buffer's data keys")
+	flags.StringVar(&c.Dispatch.Mode, "dispatch-mode", c.Dispatch.Mode, "how buffered logs are sent to the collector: batch, individual or stream")
+	flags.DurationVar(&c.Dispatch.FlushInterval, "flush-interval", c.Dispatch.FlushInterval, "interval between buffer flushes")
+	flags.IntVar(&c.Dispatch.BatchSize, "batch-size", c.Dispatch.BatchSize, "maximum number of logs per collector request")
+	flags.BoolVar(&c.Dispatch.AllOrNothing, "all-or-nothing", c.Dispatch.AllOrNothing, "persist no log of a batch when the collector finds one of them invalid")
+	flags.IntVar(&c.Limits.MaxMetadataBytes, "max-metadata-bytes", c.Limits.MaxMetadataBytes, "largest metadata accepted in a log, in bytes")
+	flags.IntVar(&c.Limits.MaxMetadataDepth, "max-metadata-depth", c.Limits.MaxMetadataDepth, "deepest nesting of metadata accepted in a log")
+	flags.IntVar(&c.Retry.MaxAttempts, "retry-max-attempts", c.Retry.MaxAttempts, "attempts at a collector call failing for transient reasons")
+	flags.DurationVar(&c.Retry.InitialBackoff, "retry-initial-backoff", c.Retry.InitialBackoff, "longest wait before the first retry")
+	flags.DurationVar(&c.Retry.MaxBackoff, "retry-max-backoff", c.Retry.MaxBackoff, "longest wait between retries")
+	flags.IntVar(&c.CircuitBreaker.FailureThreshold, "circuit-breaker-threshold", c.CircuitBreaker.FailureThreshold, "failed collector calls in a row after which calls stop")
+	flags.DurationVar(&c.CircuitBreaker.Cooldown, "circuit-breaker-cooldown", c.CircuitBreaker.Cooldown, "time before calling a failing collector again")
+	flags.IntVar(&c.DeadLetter.MaxRejections, "dead-letter-max-rejections", c.DeadLetter.MaxRejections, "rejections of a log by the collector before it is dead-lettered")
+	flags.DurationVar(&c.Heartbeat.Interval, "heartbeat-interval", c.Heartbeat.Interval, "interval between reports of the agent to the collector's registry")
+	flags.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time allowed to drain requests and flush the buffer on shutdown")
+	c.TLS.RegisterFlags(flags, "")
+	c.Collector.TLS.RegisterFlags(flags, "collector-")
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Name == "" {
+		errs = append(errs, fmt.Errorf("name is required"))
+	}
+
+	if c.ListenAddress == "" {
+		errs = append(errs, fmt.Errorf("listen_address is required"))
+	}
+
+	if len(c.Collector.Endpoints) == 0 {
+		errs = append(errs, fmt.Errorf("at least one collector endpoint is required"))
+	}
+
+	for _, endpoint := range c.Collector.Endpoints {
+		if strings.TrimSpace(endpoint) == "" {
+			errs = append(errs, fmt.Errorf("collector endpoints must not be empty"))
+			break
+		}
+	}
+
+	if c.Buffer.Dir == "" {
+		errs = append(errs, fmt.Errorf("buffer.dir is required"))
+	}
+
+	if c.Buffer.MaxRecords < 0 || c.Buffer.MaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("buffer limits must not be negative"))
+	}
+
+	if overflow, err := ParseOverflowPolicy(c.Buffer.Overflow); err != nil {
+		errs = append(errs, err)
+	} else if overflow == OverflowSpill && (c.Buffer.SpillDir == "" || c.Buffer.SpillDir == c.Buffer.Dir) {
+		errs = append(errs, fmt.Errorf("buffer.spill_dir is required with the spill overflow policy and must differ from buffer.dir"))
+	}
+
+	if _, err := LoadEncryptionKey(c.Buffer.Encryption); err != nil {
+		errs = append(errs, fmt.Errorf("buffer.encryption: %w", err))
+	}
+
+	if c.Buffer.Encryption.DataKeyRotation <= 0 {
+		errs = append(errs, fmt.Errorf("buffer.encryption.data_key_rotation must be positive"))
+	}
+
+	if _, err := ParseDispatchMode(c.Dispatch.Mode); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Dispatch.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("dispatch.flush_interval must be positive"))
+	}
+
+	if c.Dispatch.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("dispatch.batch_size must be positive"))
+	}
+
+	if c.Limits.MaxMetadataBytes <= 0 || c.Limits.MaxMetadataDepth <= 0 {
+		errs = append(errs, fmt.Errorf("limits must be positive"))
+	}
+
+	if c.Retry.MaxAttempts <= 0 || c.Retry.InitialBackoff <= 0 || c.Retry.MaxBackoff < c.Retry.InitialBackoff {
+		errs = append(errs, fmt.Errorf("retry needs positive max_attempts and initial_backoff, and max_backoff no shorter than initial_backoff"))
+	}
+
+	if c.CircuitBreaker.FailureThreshold <= 0 || c.CircuitBreaker.Cooldown <= 0 {
+		errs = append(errs, fmt.Errorf("circuit_breaker.failure_threshold and circuit_breaker.cooldown must be positive"))
+	}
+
+	if c.DeadLetter.MaxRejections <= 0 {
+		errs = append(errs, fmt.Errorf("dead_letter.max_rejections must be positive"))
+	}
+
+	if c.Heartbeat.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("heartbeat.interval must be positive"))
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout must be positive"))
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("tls: %w", err))
+	}
+
+	if err := c.Collector.TLS.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("collector.tls: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Print writes the effective configuration as YAML.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+
+	return encoder.Encode(c)
+}
@@ -0,0 +1,314 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"oversee/core"
+	"strconv"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// Keys of the buffer that are not buffered logs. Rejection counts are kept
+// next to the logs they count, and dead letters are logs set aside after too
+// many rejections so they are no longer sent.
+const (
+	rejectionsPrefix = "rejections/"
+	deadLetterPrefix = "deadletter/"
+)
+
+// DeadLetter is a log the collector kept rejecting.
+type DeadLetter struct {
+	// Key is the buffer key the log had.
+	Key string `json:"key"`
+	// Value is the canonical encoding of the log.
+	Value          []byte    `json:"value"`
+	Reason         string    `json:"reason"`
+	Code           int32     `json:"code"`
+	Attempts       int       `json:"attempts"`
+	DeadLetteredAt time.Time `json:"dead_lettered_at"`
+}
+
+func rejectionsKey(key []byte) []byte {
+	return append([]byte(rejectionsPrefix), key...)
+}
+
+func deadLetterKey(key []byte) []byte {
+	return append([]byte(deadLetterPrefix), key...)
+}
+
+// remove deletes a log that reached the collector from the buffer.
+func (agent *Agent) remove(key []byte) error {
+	var size int64 = -1
+
+	err := agent.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+
+		// A log sent twice is acknowledged twice.
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		size = item.ValueSize()
+
+		if err = txn.Delete(key); err != nil {
+			return err
+		}
+
+		return txn.Delete(rejectionsKey(key))
+	})
+
+	if err == nil && size >= 0 {
+		agent.released(size)
+	}
+
+	return err
+}
+
+// reject counts a rejection of the buffered log at key by the collector,
+// moving it to the dead letters once it was rejected maxRejections times.
+func (agent *Agent) reject(key []byte, reason string, code int32) error {
+	var size int64 = -1
+
+	err := agent.db.Update(func(txn *badger.Txn) error {
+		rejections := 0
+		item, err := txn.Get(rejectionsKey(key))
+
+		switch {
+		case err == nil:
+			value, err := item.ValueCopy(nil)
+
+			if err != nil {
+				return err
+			}
+
+			if rejections, err = strconv.Atoi(string(value)); err != nil {
+				return fmt.Errorf("failed to read rejections of %s: %w", key, err)
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		rejections++
+
+		if rejections < agent.maxRejections {
+			fmt.Println("Collector rejected", string(key), reason)
+			return txn.Set(rejectionsKey(key), []byte(strconv.Itoa(rejections)))
+		}
+
+		size, err = deadLetter(txn, key, reason, code, rejections)
+		return err
+	})
+
+	if err == nil && size >= 0 {
+		agent.released(size)
+	}
+
+	return err
+}
+
+// deadLetterNow sets aside a buffered log that cannot be sent at all.
+func (agent *Agent) deadLetterNow(key []byte, reason string) error {
+	var size int64
+
+	err := agent.db.Update(func(txn *badger.Txn) error {
+		var err error
+		size, err = deadLetter(txn, key, reason, 0, 0)
+		return err
+	})
+
+	if err == nil {
+		agent.released(size)
+	}
+
+	return err
+}
+
+// deadLetter moves the log at key to the dead letters, returning its size.
+func deadLetter(txn *badger.Txn, key []byte, reason string, code int32, attempts int) (int64, error) {
+	item, err := txn.Get(key)
+
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := item.ValueCopy(nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	entry, err := json.Marshal(DeadLetter{
+		Key:            string(key),
+		Value:          value,
+		Reason:         reason,
+		Code:           code,
+		Attempts:       attempts,
+		DeadLetteredAt: time.Now().UTC(),
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err = txn.Set(deadLetterKey(key), entry); err != nil {
+		return 0, err
+	}
+
+	if err = txn.Delete(rejectionsKey(key)); err != nil {
+		return 0, err
+	}
+
+	fmt.Println("Dead-lettered", string(key), reason)
+	return int64(len(value)), txn.Delete(key)
+}
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetters gives access to the dead letters of a buffer directory. Badger
+// locks the directory, so the agent using it must be stopped first; logs
+// replayed are sent on its next start.
+type DeadLetters struct {
+	db *badger.DB
+}
+
+// OpenDeadLetters opens the buffer directory with its encryption key, nil
+// when it is not encrypted.
+func OpenDeadLetters(bufferDir string, key []byte) (*DeadLetters, error) {
+	db, err := badger.Open(bufferOptions(bufferDir, key, 0).WithLogger(nil))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open buffer %s: %w", bufferDir, err)
+	}
+
+	return &DeadLetters{db: db}, nil
+}
+
+func (d *DeadLetters) List() ([]*DeadLetter, error) {
+	deadLetters := []*DeadLetter{}
+
+	err := d.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte(deadLetterPrefix)
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			deadLetter, err := readDeadLetter(it.Item())
+
+			if err != nil {
+				return err
+			}
+
+			deadLetters = append(deadLetters, deadLetter)
+		}
+
+		return nil
+	})
+
+	return deadLetters, err
+}
+
+func (d *DeadLetters) Get(key string) (*DeadLetter, error) {
+	var deadLetter *DeadLetter
+
+	err := d.db.View(func(txn *badger.Txn) error {
+		var err error
+		deadLetter, err = getDeadLetter(txn, key)
+		return err
+	})
+
+	return deadLetter, err
+}
+
+// Edit replaces the log of a dead letter, which stays dead-lettered until it
+// is replayed.
+func (d *DeadLetters) Edit(key string, log *core.Log) error {
+	value, err := log.Canonical()
+
+	if err != nil {
+		return err
+	}
+
+	return d.db.Update(func(txn *badger.Txn) error {
+		deadLetter, err := getDeadLetter(txn, key)
+
+		if err != nil {
+			return err
+		}
+
+		deadLetter.Value = value
+		entry, err := json.Marshal(deadLetter)
+
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(deadLetterKey([]byte(key)), entry)
+	})
+}
+
+// Replay puts a dead letter back in the buffer with no rejections counted.
+func (d *DeadLetters) Replay(key string) error {
+	return d.db.Update(func(txn *badger.Txn) error {
+		deadLetter, err := getDeadLetter(txn, key)
+
+		if err != nil {
+			return err
+		}
+
+		if err = txn.Set([]byte(key), deadLetter.Value); err != nil {
+			return err
+		}
+
+		return txn.Delete(deadLetterKey([]byte(key)))
+	})
+}
+
+func (d *DeadLetters) Purge(key string) error {
+	return d.db.Update(func(txn *badger.Txn) error {
+		if _, err := getDeadLetter(txn, key); err != nil {
+			return err
+		}
+
+		return txn.Delete(deadLetterKey([]byte(key)))
+	})
+}
+
+func (d *DeadLetters) Close() error {
+	return d.db.Close()
+}
+
+func getDeadLetter(txn *badger.Txn, key string) (*DeadLetter, error) {
+	item, err := txn.Get(deadLetterKey([]byte(key)))
+
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return readDeadLetter(item)
+}
+
+func readDeadLetter(item *badger.Item) (*DeadLetter, error) {
+	deadLetter := &DeadLetter{}
+
+	err := item.Value(func(value []byte) error {
+		return json.Unmarshal(value, deadLetter)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter %s: %w", item.Key(), err)
+	}
+
+	return deadLetter, nil
+}
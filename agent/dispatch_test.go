@@ -0,0 +1,268 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"oversee/collector/audit"
+	"oversee/collector/persistence/sqlite"
+	"oversee/core"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rejectingCollector is a collector that rejects one log as invalid, the
+// way the collector does: in the details of the status of a single log, and
+// in the reason of its result in a batch.
+type rejectingCollector struct {
+	audit.LogsIngestionAPI
+	rejected string
+}
+
+var errRejectedByTest = core.ErrorWithMessage(core.ErrorCodeInvalidLog, "Rejected By Test")
+
+func (c *rejectingCollector) PersistLog(ctx context.Context, request *audit.PersistLogRequest) (*audit.PersistLogReply, error) {
+	if request.GetLog().GetId() == c.rejected {
+		st, err := status.New(codes.InvalidArgument, errRejectedByTest.Error()).WithDetails(&audit.Error{
+			Message: errRejectedByTest.Message,
+			Code:    int32(errRejectedByTest.Code),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	}
+
+	return c.LogsIngestionAPI.PersistLog(ctx, request)
+}
+
+func (c *rejectingCollector) BatchPersistLog(ctx context.Context, request *audit.BatchPersistLogRequest) (*audit.PersistLogsReply, error) {
+	accepted := []*audit.Log{}
+	for _, log := range request.GetLogs() {
+		if log.GetId() != c.rejected {
+			accepted = append(accepted, log)
+		}
+	}
+
+	reply, err := c.LogsIngestionAPI.BatchPersistLog(ctx, &audit.BatchPersistLogRequest{
+		Logs:         accepted,
+		AgentId:      request.AgentId,
+		AllOrNothing: request.AllOrNothing,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*audit.PersistLogReply{}
+	for _, log := range request.GetLogs() {
+		if log.GetId() == c.rejected {
+			results = append(results, &audit.PersistLogReply{
+				Id:     log.GetId(),
+				Reason: &audit.Error{Message: errRejectedByTest.Message, Code: int32(errRejectedByTest.Code)},
+			})
+			continue
+		}
+
+		results = append(results, reply.Results[0])
+		reply.Results = reply.Results[1:]
+	}
+
+	return &audit.PersistLogsReply{Results: results}, nil
+}
+
+// startCollector serves a collector storing logs in a SQLite database and
+// rejecting the log with ID rejected.
+func startCollector(t *testing.T, rejected uuid.UUID) (string, *sqlite.SQLitePersistence) {
+	t.Helper()
+
+	p, err := sqlite.NewSQLitePersistence(filepath.Join(t.TempDir(), "collector.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	s := grpc.NewServer()
+	audit.RegisterCollectorServer(s, &rejectingCollector{
+		LogsIngestionAPI: *audit.NewLogsIngestionAPI(p, "", nil, nil),
+		rejected:         rejected.String(),
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	return listener.Addr().String(), p
+}
+
+func newDispatchTestLog(operation string) *core.Log {
+	return &core.Log{
+		ID:                uuid.New(),
+		Timestamp:         time.Now().UTC(),
+		ServiceName:       "billing",
+		Operation:         operation,
+		ActorId:           "user-1",
+		ActorType:         "user",
+		AffectedResources: []string{"invoice/1", "customer/1"},
+		Metadata:          map[string]any{"amount": 12.5, "currency": "EUR", "paid": true},
+	}
+}
+
+func newDispatchTestLogs() []*core.Log {
+	return []*core.Log{
+		newDispatchTestLog("invoice.create"),
+		newDispatchTestLog("invoice.send"),
+		newDispatchTestLog("invoice.pay"),
+		newDispatchTestLog("invoice.close"),
+	}
+}
+
+// dispatchLogs flushes four logs through an agent in the dispatch mode, to
+// a collector that already has the second one and rejects the third, which
+// is dead-lettered on its first rejection. It returns the logs the collector
+// stored, how many logs the agent kept and its dead letters.
+func dispatchLogs(t *testing.T, mode string, logs []*core.Log) ([]*core.Log, int64, []*DeadLetter) {
+	t.Helper()
+
+	address, p := startCollector(t, logs[2].ID)
+	ctx := context.Background()
+
+	persisted := *logs[1]
+	if _, err := p.PersistLog(ctx, &persisted); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.DeadLetter.MaxRejections = 1
+	bufferDir := t.TempDir()
+
+	a, err := New(
+		WithConfig(config),
+		WithName("dispatch-test"),
+		WithBufferDir(bufferDir),
+		WithCollector(address),
+		WithFlushInterval(time.Hour),
+		WithHeartbeatInterval(time.Hour),
+		WithBatchSize(10),
+		WithDispatchMode(mode),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, log := range logs {
+		l := *log
+		if err = a.Log(ctx, &l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = a.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	kept := a.BufferStatus().Records
+
+	if err = a.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := p.ListLogs(ctx, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, log := range []*core.Log{logs[0], logs[1], logs[3]} {
+		if !containsLog(stored, log) {
+			t.Errorf("%s dispatch: the collector does not have %s as logged", mode, log.Operation)
+		}
+	}
+
+	if len(stored) != 3 {
+		t.Errorf("%s dispatch: the collector has %d logs, want 3", mode, len(stored))
+	}
+
+	deadLetters, err := OpenDeadLetters(bufferDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deadLetters.Close()
+
+	dead, err := deadLetters.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stored, kept, dead
+}
+
+// containsLog tells whether logs hold a log with the same ID and body as
+// log.
+func containsLog(logs []*core.Log, log *core.Log) bool {
+	for _, l := range logs {
+		if l.ID == log.ID &&
+			l.Timestamp.Equal(log.Timestamp) &&
+			l.ServiceName == log.ServiceName &&
+			l.Operation == log.Operation &&
+			l.ActorId == log.ActorId &&
+			l.ActorType == log.ActorType &&
+			reflect.DeepEqual(l.AffectedResources, log.AffectedResources) &&
+			reflect.DeepEqual(l.Metadata, log.Metadata) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestIndividualDispatchSendsFullLogs(t *testing.T) {
+	logs := newDispatchTestLogs()
+	_, kept, dead := dispatchLogs(t, "individual", logs)
+
+	if kept != 0 {
+		t.Errorf("the agent kept %d logs, want none", kept)
+	}
+
+	if len(dead) != 1 || !strings.Contains(dead[0].Key, logs[2].ID.String()) {
+		t.Fatalf("the dead letters are %+v, want the rejected log", dead)
+	}
+
+	if dead[0].Code != int32(core.ErrorCodeInvalidLog) {
+		t.Errorf("the rejected log was dead-lettered with code %d, want %d", dead[0].Code, core.ErrorCodeInvalidLog)
+	}
+}
+
+func TestIndividualDispatchMatchesBatch(t *testing.T) {
+	logs := newDispatchTestLogs()
+	individual, individualKept, individualDead := dispatchLogs(t, "individual", logs)
+	batch, batchKept, batchDead := dispatchLogs(t, "batch", logs)
+
+	if individualKept != batchKept {
+		t.Errorf("individual dispatch kept %d logs, batch dispatch %d", individualKept, batchKept)
+	}
+
+	if len(individualDead) != 1 || len(batchDead) != 1 {
+		t.Fatalf("individual dispatch dead-lettered %d logs, batch dispatch %d, want the rejected one", len(individualDead), len(batchDead))
+	}
+
+	if individualDead[0].Key != batchDead[0].Key || individualDead[0].Code != batchDead[0].Code {
+		t.Errorf("individual dispatch dead-lettered %s with code %d, batch dispatch %s with code %d",
+			individualDead[0].Key, individualDead[0].Code, batchDead[0].Key, batchDead[0].Code)
+	}
+
+	for _, log := range individual {
+		if !containsLog(batch, log) {
+			t.Errorf("batch dispatch did not store %s as individual dispatch did", log.Operation)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"oversee/pkg/tlsconfig"
+	"time"
+)
+
+var ErrAgentNotStarted = errors.New("agent is not started")
+
+type options struct {
+	config     *Config
+	signingKey ed25519.PrivateKey
+}
+
+// Option customizes an agent created with New.
+type Option func(o *options)
+
+// WithConfig replaces the whole configuration. Options given after it still
+// apply on top of it.
+func WithConfig(c *Config) Option {
+	return func(o *options) {
+		*o.config = *c
+	}
+}
+
+func WithName(name string) Option {
+	return func(o *options) {
+		o.config.Name = name
+	}
+}
+
+func WithApplication(name string, version string) Option {
+	return func(o *options) {
+		o.config.Application = name
+		o.config.ApplicationVersion = version
+	}
+}
+
+// WithCollector sets the collector endpoints, tried in order.
+func WithCollector(endpoints ...string) Option {
+	return func(o *options) {
+		o.config.Collector.Endpoints = endpoints
+	}
+}
+
+func WithCollectorTLS(tls tlsconfig.Config) Option {
+	return func(o *options) {
+		o.config.Collector.TLS = tls
+	}
+}
+
+func WithBufferDir(dir string) Option {
+	return func(o *options) {
+		o.config.Buffer.Dir = dir
+	}
+}
+
+// WithBufferEncryption encrypts the buffer with the hex encoded key in
+// keyFile.
+func WithBufferEncryption(keyFile string) Option {
+	return func(o *options) {
+		o.config.Buffer.Encryption.KeyFile = keyFile
+	}
+}
+
+func WithFlushInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.config.Dispatch.FlushInterval = interval
+	}
+}
+
+// WithHeartbeatInterval sets how often the agent reports itself to the
+// collector's agent registry.
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.config.Heartbeat.Interval = interval
+	}
+}
+
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		o.config.Dispatch.BatchSize = size
+	}
+}
+
+// WithAllOrNothing asks the collector to persist no log of a batch when one
+// of them is invalid.
+func WithAllOrNothing() Option {
+	return func(o *options) {
+		o.config.Dispatch.AllOrNothing = true
+	}
+}
+
+// WithDispatchMode sets how logs are sent to the collector, "batch",
+// "individual" or "stream".
+func WithDispatchMode(mode string) Option {
+	return func(o *options) {
+		o.config.Dispatch.Mode = mode
+	}
+}
+
+// WithSigningKey signs the logs sent to the collector with key.
+func WithSigningKey(key ed25519.PrivateKey) Option {
+	return func(o *options) {
+		o.signingKey = key
+	}
+}
+
+// New creates and starts an agent running inside the calling process, with
+// the same buffering and dispatch as the agent sidecar but without its API.
+// Logs are written with Log, and Close must be called before the process
+// exits to flush the buffer and release it.
+//
+//	a, err := agent.New(
+//		agent.WithName("billing"),
+//		agent.WithBufferDir("/var/lib/billing/audit"),
+//		agent.WithCollector("collector:4093"),
+//	)
+//	defer a.Close(ctx)
+func New(opts ...Option) (*Agent, error) {
+	o := &options{config: DefaultConfig()}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if err := o.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	agent := NewAgent(o.config)
+	agent.SigningKey = o.signingKey
+
+	if err := agent.Start(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return agent, nil
+}
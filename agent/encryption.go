@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// LoadEncryptionKey returns the buffer encryption key of the configuration,
+// taken from Key or else read from KeyFile. Keys are hex encoded AES keys of
+// 16, 24 or 32 bytes. It returns a nil key when neither is set, the buffer
+// being stored in plaintext.
+func LoadEncryptionKey(config EncryptionConfig) ([]byte, error) {
+	encoded := config.Key
+
+	if encoded == "" && config.KeyFile != "" {
+		data, err := os.ReadFile(config.KeyFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key: %w", err)
+		}
+
+		encoded = string(data)
+	}
+
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(encoded))
+
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not hex encoded: %w", err)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("encryption key is %d bytes, it must be 16, 24 or 32", len(key))
+	}
+}
+
+// GenerateEncryptionKey returns a new hex encoded 32 bytes key.
+func GenerateEncryptionKey() (string, error) {
+	key := make([]byte, 32)
+
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+// bufferOptions returns the Badger options of a buffer directory, encrypted
+// when key is set. Badger encrypts data with data keys it rotates every
+// dataKeyRotation, themselves encrypted with key.
+func bufferOptions(dir string, key []byte, dataKeyRotation time.Duration) badger.Options {
+	// Badger requires an index cache to read encrypted tables, which a
+	// buffer whose key was rotated to none still holds.
+	options := badger.DefaultOptions(dir).WithIndexCacheSize(64 << 20)
+
+	if len(key) == 0 {
+		return options
+	}
+
+	options = options.WithEncryptionKey(key)
+
+	if dataKeyRotation > 0 {
+		options = options.WithEncryptionKeyRotationDuration(dataKeyRotation)
+	}
+
+	return options
+}
+
+// VerifyEncryptionKey checks that the buffer directory is encrypted with key,
+// or in plaintext when key is empty. It only reads the key registry, so it
+// also works on the buffer of a running agent.
+func VerifyEncryptionKey(dir string, key []byte) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+
+	// A read-only registry opens empty when its file is missing, which
+	// would accept any key for a directory that is not a buffer.
+	if _, err := os.Stat(filepath.Join(dir, badger.KeyRegistryFileName)); err != nil {
+		return fmt.Errorf("not a buffer, it has no key registry: %w", err)
+	}
+
+	_, err := badger.OpenKeyRegistry(badger.KeyRegistryOptions{
+		Dir:           dir,
+		ReadOnly:      true,
+		EncryptionKey: key,
+	})
+
+	return err
+}
+
+// RotateEncryptionKey re-encrypts the data keys of buffer directories, the
+// buffer and its spill directory, with newKey. Either key may be empty to
+// encrypt a plaintext buffer, or decrypt one. Only the data keys change:
+// data written before stays as it was, so a plaintext buffer rotated to a
+// key keeps its existing logs unencrypted until they are flushed, and a
+// buffer rotated to no key keeps its tables encrypted with data keys that
+// are now stored in plaintext. Every directory is checked to open with oldKey before any is
+// changed, so that they keep sharing a key. The agent using them must be
+// stopped.
+func RotateEncryptionKey(dirs []string, oldKey []byte, newKey []byte) error {
+	registries := make([]*badger.KeyRegistry, len(dirs))
+
+	for i, dir := range dirs {
+		// Opening the buffer checks the old key and that no agent holds it.
+		db, err := badger.Open(bufferOptions(dir, oldKey, 0).WithLogger(nil))
+
+		if err != nil {
+			return fmt.Errorf("failed to open buffer %s: %w", dir, err)
+		}
+
+		if err = db.Close(); err != nil {
+			return err
+		}
+
+		registries[i], err = badger.OpenKeyRegistry(badger.KeyRegistryOptions{
+			Dir:           dir,
+			ReadOnly:      true,
+			EncryptionKey: oldKey,
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to read key registry of %s: %w", dir, err)
+		}
+	}
+
+	for i, dir := range dirs {
+		err := badger.WriteKeyRegistry(registries[i], badger.KeyRegistryOptions{
+			Dir:           dir,
+			EncryptionKey: newKey,
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to rotate the key of %s: %w", dir, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"bytes"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// writeBuffer opens the buffer in dir with key, sets a value and closes
+// it, which writes the value to a table.
+func writeBuffer(t *testing.T, dir string, key []byte) {
+	t.Helper()
+
+	db, err := badger.Open(bufferOptions(dir, key, 0).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("log/1"), []byte("value"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readBufferValue fails the test unless the buffer in dir opens with key and
+// holds the value written by writeBuffer.
+func readBufferValue(t *testing.T, dir string, key []byte) {
+	t.Helper()
+
+	db, err := badger.Open(bufferOptions(dir, key, 0).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("log/1"))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(value []byte) error {
+			if !bytes.Equal(value, []byte("value")) {
+				t.Errorf("read %q, want %q", value, "value")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateEncryptionKey(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	tests := []struct {
+		name   string
+		oldKey []byte
+		newKey []byte
+	}{
+		{"to another key", oldKey, newKey},
+		{"to no key", oldKey, nil},
+		{"from no key", nil, newKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			spillDir := t.TempDir()
+
+			writeBuffer(t, dir, test.oldKey)
+			writeBuffer(t, spillDir, test.oldKey)
+
+			if err := RotateEncryptionKey([]string{dir, spillDir}, test.oldKey, test.newKey); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, d := range []string{dir, spillDir} {
+				if err := VerifyEncryptionKey(d, test.newKey); err != nil {
+					t.Errorf("VerifyEncryptionKey() = %v with the new key", err)
+				}
+
+				readBufferValue(t, d, test.newKey)
+			}
+		})
+	}
+}
+
+func TestVerifyEncryptionKey(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	dir := t.TempDir()
+	writeBuffer(t, dir, key)
+
+	if err := VerifyEncryptionKey(dir, key); err != nil {
+		t.Errorf("VerifyEncryptionKey() = %v with the buffer's key", err)
+	}
+
+	if err := VerifyEncryptionKey(dir, bytes.Repeat([]byte{2}, 32)); err == nil {
+		t.Error("VerifyEncryptionKey() accepted another key")
+	}
+
+	if err := VerifyEncryptionKey(t.TempDir(), key); err == nil {
+		t.Error("VerifyEncryptionKey() accepted a directory that is not a buffer")
+	}
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"oversee/collector/audit"
+	"oversee/pkg/version"
+	"runtime"
+	"time"
+
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// heartbeat reports the agent and the depth of its buffer to the
+// collector's agent registry, signed like the logs when the agent has a
+// signing key. stopping tells the collector the agent is shutting down
+// rather than going silent.
+func (agent *Agent) heartbeat(ctx context.Context, stopping bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hostname, _ := os.Hostname()
+	buffer := agent.BufferStatus()
+
+	request := &audit.RegisterAgentRequest{
+		AgentId:            agent.Name,
+		Application:        agent.Application.Name,
+		ApplicationVersion: agent.Application.Version,
+		AgentVersion:       version.GetVersion().VersionNumber(),
+		Host: &audit.HostInfo{
+			Hostname: hostname,
+			Os:       runtime.GOOS,
+			Arch:     runtime.GOARCH,
+			Pid:      int64(os.Getpid()),
+		},
+		Buffer: &audit.BufferDepth{
+			Records:        buffer.Records,
+			Bytes:          buffer.Bytes,
+			SpilledRecords: buffer.SpilledRecords,
+		},
+		StartedAt: timestamppb.New(agent.Application.InitializedAt),
+		Stopping:  stopping,
+	}
+
+	if agent.SigningKey != nil {
+		if err := audit.SignHeartbeat(agent.SigningKey, request); err != nil {
+			return fmt.Errorf("failed to sign heartbeat: %w", err)
+		}
+	}
+
+	_, err := agent.collectorClient.RegisterAgent(ctx, request)
+
+	return err
+}
+
+// heartbeatPeriodically registers the agent right away, then refreshes the
+// registration every heartbeat interval until ctx is done. Failures are
+// only logged, the registry has no say in whether logs are dispatched.
+func (agent *Agent) heartbeatPeriodically(ctx context.Context) {
+	defer close(agent.heartbeatDone)
+
+	ticker := time.NewTicker(agent.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := agent.heartbeat(ctx, false); err != nil && ctx.Err() == nil {
+			fmt.Println("Heartbeat failed:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// metadataFromRequest converts the metadata of a Log request, rejecting it
+// when it is larger or more deeply nested than the limits allow.
+func metadataFromRequest(metadata *structpb.Struct, limits LimitsConfig) (map[string]any, error) {
+	if metadata == nil {
+		return map[string]any{}, nil
+	}
+
+	if size := proto.Size(metadata); size > limits.MaxMetadataBytes {
+		return nil, fmt.Errorf("metadata is %d bytes, more than the %d allowed", size, limits.MaxMetadataBytes)
+	}
+
+	if depth := structDepth(metadata); depth > limits.MaxMetadataDepth {
+		return nil, fmt.Errorf("metadata is nested %d levels deep, more than the %d allowed", depth, limits.MaxMetadataDepth)
+	}
+
+	return metadata.AsMap(), nil
+}
+
+// structDepth counts the levels of nested structs and lists, a flat struct
+// being one level deep.
+func structDepth(s *structpb.Struct) int {
+	depth := 0
+	for _, value := range s.GetFields() {
+		depth = max(depth, valueDepth(value))
+	}
+	return depth + 1
+}
+
+func valueDepth(value *structpb.Value) int {
+	switch v := value.GetKind().(type) {
+	case *structpb.Value_StructValue:
+		return structDepth(v.StructValue)
+	case *structpb.Value_ListValue:
+		depth := 0
+		for _, item := range v.ListValue.GetValues() {
+			depth = max(depth, valueDepth(item))
+		}
+		return depth + 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrCircuitOpen = errors.New("collector circuit is open")
+
+type failureKind int
+
+const (
+	// failureTransient errors come from reaching the collector and are
+	// retried.
+	failureTransient failureKind = iota + 1
+	// failureRejected errors are the collector refusing the logs sent.
+	failureRejected
+	// failureFatal errors, such as authentication failures, would fail the
+	// same way again and are not retried.
+	failureFatal
+)
+
+// classify tells how a failed collector call is handled from its gRPC code.
+func classify(err error) failureKind {
+	st, ok := status.FromError(err)
+	if !ok {
+		return failureFatal
+	}
+
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown:
+		return failureTransient
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return failureRejected
+	default:
+		return failureFatal
+	}
+}
+
+// retryPolicy retries transient failures with exponential backoff and full
+// jitter.
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func (p retryPolicy) do(ctx context.Context, call func(ctx context.Context) error) error {
+	backoff := p.initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := call(ctx)
+
+		if err == nil || classify(err) != failureTransient || attempt >= p.maxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(rand.N(backoff) + 1):
+		}
+
+		backoff = min(backoff*2, p.maxBackoff)
+	}
+}
+
+// circuitBreaker stops calls to the collector once failureThreshold calls in
+// a row failed transiently, and lets calls through again after cooldown.
+// A failure after the cooldown opens it again right away.
+type circuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures < b.failureThreshold || time.Since(b.openedAt) >= b.cooldown
+}
+
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || classify(err) != failureTransient {
+		b.failures = 0
+		return
+	}
+
+	b.fail()
+}
+
+// recordFailure records a transient failure that has no gRPC status.
+func (b *circuitBreaker) recordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.fail()
+}
+
+func (b *circuitBreaker) fail() {
+	b.failures++
+	if b.failures >= b.failureThreshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// callCollector makes a collector call through the circuit breaker, retrying
+// it according to the retry policy.
+func (agent *Agent) callCollector(ctx context.Context, call func(ctx context.Context) error) error {
+	return agent.retry.do(ctx, func(ctx context.Context) error {
+		if !agent.breaker.allow() {
+			return ErrCircuitOpen
+		}
+
+		err := call(ctx)
+		agent.breaker.record(err)
+
+		return err
+	})
+}
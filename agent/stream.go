@@ -0,0 +1,288 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"oversee/collector/audit"
+	"oversee/core"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// logStream pushes logs to the collector over a single StreamLogs call and
+// deletes them from the buffer as the collector acknowledges them. The call
+// is opened on first use and again after it fails, logs that were not
+// acknowledged being sent again by the next flush.
+type logStream struct {
+	agent *Agent
+
+	// mu serializes sends, which a gRPC stream does not allow concurrently.
+	mu     sync.Mutex
+	stream grpc.BidiStreamingClient[audit.StreamLogsRequest, audit.PersistLogReply]
+	cancel context.CancelFunc
+	done   chan struct{}
+	// caughtUp is set once a flush sent every buffered log on the current
+	// stream. Until then, new logs are left to flushes rather than sent
+	// right away, which would have them overtake older ones.
+	caughtUp bool
+	// generation counts the streams opened and reset, telling a flush
+	// whether the stream it caught up on is still the current one.
+	generation int
+
+	// inFlight maps the IDs of logs sent but not acknowledged yet to their
+	// buffer keys.
+	inFlightMu sync.Mutex
+	inFlight   map[string][]byte
+
+	// live holds the logs buffered since the last live send, queued in the
+	// order of their sequence numbers while the buffer is locked.
+	liveMu sync.Mutex
+	live   []liveLog
+}
+
+type liveLog struct {
+	key []byte
+	log *core.Log
+}
+
+func newLogStream(agent *Agent) *logStream {
+	return &logStream{
+		agent:    agent,
+		inFlight: map[string][]byte{},
+	}
+}
+
+// queueLive queues a log for the next live send. Callers hold the agent's
+// usageMu, under which the log got its sequence number.
+func (s *logStream) queueLive(key []byte, log *core.Log) {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+
+	s.live = append(s.live, liveLog{key: key, log: log})
+}
+
+// sendLive pushes the queued logs, once the stream caught up with the
+// buffer. Logs are taken off the queue and sent under mu, so that they go
+// out in the order they were buffered whichever call sends them.
+func (s *logStream) sendLive() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.liveMu.Lock()
+	live := s.live
+	s.live = nil
+	s.liveMu.Unlock()
+
+	// Logs that are not sent now are left to the next flush.
+	if !s.caughtUp {
+		return nil
+	}
+
+	for _, l := range live {
+		if err := s.sendLocked(l.key, l.log); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// catchingUp is called when a flush starts. The function it returns marks
+// the stream caught up once the flush sent every buffered log, unless the
+// stream failed in between. A flush starting without stream may open one.
+func (s *logStream) catchingUp() func() {
+	s.mu.Lock()
+	generation := s.generation
+	opened := s.stream != nil
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.generation == generation || (!opened && s.generation == generation+1) {
+			s.caughtUp = true
+		}
+	}
+}
+
+// send pushes a buffered log unless it is already waiting for its
+// acknowledgement.
+func (s *logStream) send(key []byte, log *core.Log) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sendLocked(key, log)
+}
+
+func (s *logStream) sendLocked(key []byte, log *core.Log) error {
+	id := log.ID.String()
+
+	s.inFlightMu.Lock()
+	_, sent := s.inFlight[id]
+	s.inFlightMu.Unlock()
+
+	if sent {
+		return nil
+	}
+
+	if s.stream == nil {
+		if !s.agent.breaker.allow() {
+			return ErrCircuitOpen
+		}
+
+		err := s.open()
+		s.agent.breaker.record(err)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	logsAPILog, err := CoreLogToLogsAPILog(log)
+
+	if err != nil {
+		return err
+	}
+
+	logsAPILog.AgentSequence, _ = keySequence(key)
+
+	signature, err := s.agent.sign([]*core.Log{log})
+
+	if err != nil {
+		return err
+	}
+
+	// The acknowledgement may arrive before Send returns.
+	s.inFlightMu.Lock()
+	s.inFlight[id] = key
+	s.inFlightMu.Unlock()
+
+	err = s.stream.Send(&audit.StreamLogsRequest{
+		Log:       logsAPILog,
+		AgentId:   s.agent.Name,
+		Signature: signature,
+	})
+
+	if err != nil {
+		s.agent.breaker.recordFailure()
+		s.cancel()
+		s.reset(s.stream)
+		return fmt.Errorf("failed to stream log: %w", err)
+	}
+
+	return nil
+}
+
+func (s *logStream) open() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := s.agent.collectorClient.StreamLogs(ctx)
+
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	s.stream = stream
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.generation++
+
+	go s.receive(stream, cancel, s.done)
+
+	return nil
+}
+
+// reset forgets stream, if it is still the current one, and the logs sent
+// on it. Callers hold mu.
+func (s *logStream) reset(stream grpc.BidiStreamingClient[audit.StreamLogsRequest, audit.PersistLogReply]) {
+	if s.stream != stream {
+		return
+	}
+
+	s.stream = nil
+	s.caughtUp = false
+	s.generation++
+
+	s.inFlightMu.Lock()
+	clear(s.inFlight)
+	s.inFlightMu.Unlock()
+}
+
+func (s *logStream) receive(stream grpc.BidiStreamingClient[audit.StreamLogsRequest, audit.PersistLogReply], cancel context.CancelFunc, done chan struct{}) {
+	defer close(done)
+
+	for {
+		reply, err := stream.Recv()
+
+		if err != nil {
+			if err != io.EOF {
+				fmt.Println("Log stream ended:", err)
+			}
+
+			// Cancelling first unblocks a send waiting on the stream.
+			cancel()
+			s.mu.Lock()
+			s.reset(stream)
+			s.mu.Unlock()
+			return
+		}
+
+		if err = s.acknowledge(reply); err != nil {
+			fmt.Println("Failed to remove", reply.Id, "from the buffer:", err)
+		}
+	}
+}
+
+func (s *logStream) acknowledge(reply *audit.PersistLogReply) error {
+	s.inFlightMu.Lock()
+	key, ok := s.inFlight[reply.Id]
+	delete(s.inFlight, reply.Id)
+	s.inFlightMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if !reply.GetSuccess() && isRetained(reply.GetReason().GetCode()) {
+		// The next flush sends the log again.
+		fmt.Println("Collector failed to store", reply.Id, reply.GetReason().GetMessage())
+		return nil
+	}
+
+	if !reply.GetSuccess() && reply.GetReason().GetCode() != core.ErrorCodeAlreadyPersistedLog {
+		return s.agent.reject(key, reply.GetReason().GetMessage(), reply.GetReason().GetCode())
+	}
+
+	fmt.Println("Persisted", reply.Id)
+	return s.agent.remove(key)
+}
+
+// close ends the stream once the collector acknowledged every log sent, or
+// when ctx is done.
+func (s *logStream) close(ctx context.Context) error {
+	s.mu.Lock()
+	stream, cancel, done := s.stream, s.cancel, s.done
+	s.mu.Unlock()
+
+	if stream == nil {
+		return nil
+	}
+
+	defer cancel()
+
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,244 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"oversee/agent"
+	"oversee/pkg/tlsconfig"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrQueueFull = errors.New("async queue is full")
+	ErrClosed    = errors.New("client is closed")
+)
+
+// Client emits audit events to an agent. A single connection is shared by
+// every event, so a Client should be created once and reused.
+type Client struct {
+	conn   *grpc.ClientConn
+	agent  agent.AgentClient
+	config clientConfig
+
+	queue    chan *agent.LogRequest
+	workers  sync.WaitGroup
+	closeMu  sync.RWMutex
+	closed   bool
+	stopOnce sync.Once
+}
+
+type clientConfig struct {
+	serviceName  string
+	tls          *tlsconfig.Config
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	queueSize    int
+	workers      int
+	fallback     *agent.Agent
+	errorHandler func(error)
+}
+
+// Option customizes a Client created with New.
+type Option func(config *clientConfig)
+
+// WithService sets the service name of every event, unless an event sets
+// its own.
+func WithService(name string) Option {
+	return func(config *clientConfig) {
+		config.serviceName = name
+	}
+}
+
+func WithTLS(tls *tlsconfig.Config) Option {
+	return func(config *clientConfig) {
+		config.tls = tls
+	}
+}
+
+// WithRetry sets how many times an event is sent while the agent is
+// unavailable, waiting backoff before the first retry and doubling it up to
+// maxBackoff after each one.
+func WithRetry(maxAttempts int, backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(config *clientConfig) {
+		config.maxAttempts = maxAttempts
+		config.backoff = backoff
+		config.maxBackoff = maxBackoff
+	}
+}
+
+// WithAsync sets the size of the queue of events sent with SendAsync and
+// the number of workers draining it.
+func WithAsync(queueSize int, workers int) Option {
+	return func(config *clientConfig) {
+		config.queueSize = queueSize
+		config.workers = workers
+	}
+}
+
+// WithFallback writes events to an embedded agent when the agent cannot be
+// reached after every retry. The embedded agent buffers them and dispatches
+// them to the collector itself. The caller keeps ownership of it and must
+// close it.
+func WithFallback(fallback *agent.Agent) Option {
+	return func(config *clientConfig) {
+		config.fallback = fallback
+	}
+}
+
+// WithErrorHandler is called with the error of every event sent with
+// SendAsync that could not be delivered.
+func WithErrorHandler(handler func(error)) Option {
+	return func(config *clientConfig) {
+		config.errorHandler = handler
+	}
+}
+
+// New connects to the agent at address.
+func New(address string, opts ...Option) (*Client, error) {
+	config := clientConfig{
+		maxAttempts: 3,
+		backoff:     100 * time.Millisecond,
+		maxBackoff:  2 * time.Second,
+		queueSize:   1024,
+		workers:     1,
+		errorHandler: func(err error) {
+			fmt.Println("Failed to send audit event:", err)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.maxAttempts < 1 || config.queueSize < 1 || config.workers < 1 {
+		return nil, fmt.Errorf("invalid client options")
+	}
+
+	creds, err := config.tls.ClientCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:   conn,
+		agent:  agent.NewAgentClient(conn),
+		config: config,
+		queue:  make(chan *agent.LogRequest, config.queueSize),
+	}
+
+	for range config.workers {
+		c.workers.Add(1)
+		go c.drain()
+	}
+
+	return c, nil
+}
+
+// Event starts building an event for an operation, such as "user.create".
+func (c *Client) Event(operation string) *Event {
+	return &Event{
+		client:    c,
+		id:        uuid.New(),
+		operation: operation,
+		service:   c.config.serviceName,
+		metadata:  map[string]any{},
+	}
+}
+
+func (c *Client) drain() {
+	defer c.workers.Done()
+
+	for request := range c.queue {
+		if err := c.send(context.Background(), request); err != nil {
+			c.config.errorHandler(err)
+		}
+	}
+}
+
+func (c *Client) enqueue(request *agent.LogRequest) error {
+	c.closeMu.RLock()
+	defer c.closeMu.RUnlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+
+	select {
+	case c.queue <- request:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// send delivers the request, retrying while the agent is unavailable and
+// falling back to the embedded agent when every attempt failed.
+func (c *Client) send(ctx context.Context, request *agent.LogRequest) error {
+	backoff := c.config.backoff
+
+	var err error
+	for attempt := 1; attempt <= c.config.maxAttempts; attempt++ {
+		_, err = c.agent.Log(ctx, request)
+
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+
+		if attempt == c.config.maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, c.config.maxBackoff)
+	}
+
+	if c.config.fallback == nil {
+		return err
+	}
+
+	return c.config.fallback.Log(ctx, requestToLog(request))
+}
+
+// Close stops accepting events, waits for queued events to be sent until
+// ctx is done and closes the connection.
+func (c *Client) Close(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		c.closeMu.Lock()
+		c.closed = true
+		close(c.queue)
+		c.closeMu.Unlock()
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		c.workers.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("%d queued events were not sent: %w", len(c.queue), ctx.Err())
+	}
+
+	return errors.Join(err, c.conn.Close())
+}
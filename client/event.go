@@ -0,0 +1,175 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"oversee/agent"
+	"oversee/core"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Event is an audit event being built. Its methods return the event itself
+// so calls can be chained, ending with Send or SendAsync.
+//
+//	err := c.Event("invoice.pay").
+//		Actor(userID, "user").
+//		Resources("invoice/" + invoiceID).
+//		Meta("amount", amount).
+//		Send(ctx)
+type Event struct {
+	client            *Client
+	operation         string
+	service           string
+	actorID           string
+	actorType         string
+	affectedResources []string
+	metadata          map[string]any
+	timestamp         time.Time
+	err               error
+
+	// id identifies the event on every attempt to send it, so that the
+	// agent and the collector store it once.
+	id uuid.UUID
+}
+
+// Service overrides the service name set on the client.
+func (e *Event) Service(name string) *Event {
+	e.service = name
+	return e
+}
+
+func (e *Event) Actor(id string, actorType string) *Event {
+	e.actorID = id
+	e.actorType = actorType
+	return e
+}
+
+// Resources adds IDs of resources affected by the operation.
+func (e *Event) Resources(ids ...string) *Event {
+	e.affectedResources = append(e.affectedResources, ids...)
+	return e
+}
+
+func (e *Event) Meta(key string, value any) *Event {
+	e.metadata[key] = value
+	return e
+}
+
+// Metadata merges a map or a struct into the event's metadata. Structs are
+// converted following their JSON encoding.
+func (e *Event) Metadata(v any) *Event {
+	fields, err := toMap(v)
+	if err != nil {
+		e.err = fmt.Errorf("invalid metadata: %w", err)
+		return e
+	}
+
+	maps.Copy(e.metadata, fields)
+	return e
+}
+
+// At sets when the operation happened. Events are stamped with the time
+// they are sent otherwise.
+func (e *Event) At(t time.Time) *Event {
+	e.timestamp = t
+	return e
+}
+
+func (e *Event) request() (*agent.LogRequest, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	if e.service == "" || e.operation == "" {
+		return nil, fmt.Errorf("service and operation are required")
+	}
+
+	timestamp := e.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	metadata, err := toMap(e.metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	metadataStruct, err := structpb.NewStruct(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	return &agent.LogRequest{
+		Id:                e.id.String(),
+		Timestamp:         timestamppb.New(timestamp),
+		ServiceName:       e.service,
+		Operation:         e.operation,
+		ActorId:           e.actorID,
+		ActorType:         e.actorType,
+		AffectedResources: e.affectedResources,
+		Metadata:          metadataStruct,
+	}, nil
+}
+
+// Send delivers the event and waits for the agent to acknowledge it.
+func (e *Event) Send(ctx context.Context) error {
+	request, err := e.request()
+	if err != nil {
+		return err
+	}
+
+	return e.client.send(ctx, request)
+}
+
+// SendAsync queues the event and returns immediately. It fails with
+// ErrQueueFull instead of blocking when the queue is full; delivery errors
+// are reported to the client's error handler.
+func (e *Event) SendAsync() error {
+	request, err := e.request()
+	if err != nil {
+		return err
+	}
+
+	return e.client.enqueue(request)
+}
+
+// toMap converts a map or struct to the generic form of its JSON encoding,
+// normalized by core.NormalizeMetadata into what structpb carries without
+// losing digits of large integers.
+func toMap(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	m := map[string]any{}
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	return core.NormalizeMetadata(m)
+}
+
+// requestToLog converts a request built by Event, whose ID is valid.
+func requestToLog(request *agent.LogRequest) *core.Log {
+	return &core.Log{
+		ID:                uuid.MustParse(request.Id),
+		Timestamp:         request.Timestamp.AsTime(),
+		ServiceName:       request.ServiceName,
+		Operation:         request.Operation,
+		ActorId:           request.ActorId,
+		ActorType:         request.ActorType,
+		AffectedResources: request.AffectedResources,
+		Metadata:          request.Metadata.AsMap(),
+	}
+}
@@ -1,12 +1,67 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
 	"oversee/agent"
+	"oversee/pkg/signing"
+	"syscall"
 )
 
+// loadConfig reads the configuration file named by -config, then lets the
+// environment and the remaining flags override it. Flags are parsed twice
+// since the file must be loaded before flags can take precedence over it.
+func loadConfig(args []string) (*agent.Config, error) {
+	var configPath string
+
+	bootstrap := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	bootstrap.StringVar(&configPath, "config", os.Getenv("OVERSEE_AGENT_CONFIG"), "path to the agent's YAML configuration")
+	agent.DefaultConfig().RegisterFlags(bootstrap)
+	bootstrap.Parse(args)
+
+	config, err := agent.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.String("config", configPath, "path to the agent's YAML configuration")
+	config.RegisterFlags(flags)
+	flags.Parse(args)
+
+	return config, nil
+}
+
 func main() {
-	server := agent.NewIngestionAPI("localhost:4093")
+	config, err := loadConfig(os.Args[1:])
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = config.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	fmt.Println("Effective configuration:")
+	config.Print(os.Stdout)
+
+	signingKey, err := signing.LoadOrCreatePrivateKey(config.SigningKey)
+
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := agent.NewIngestionAPI(config, signingKey)
 
-	fmt.Println(server.Serve())
+	if err = server.Serve(ctx); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,26 +1,66 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
-	"oversee/collector/audit"
-	"oversee/collector/graphql"
-	"oversee/collector/persistence/sqlite"
+	"os"
+	"os/signal"
+	"oversee/collector"
+	"syscall"
 )
 
+// loadConfig reads the configuration file named by -config, then lets the
+// environment and the remaining flags override it. Flags are parsed twice
+// since the file must be loaded before flags can take precedence over it.
+func loadConfig(args []string) (*collector.Config, bool, error) {
+	var configPath string
+	var checkConfig bool
+
+	bootstrap := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	bootstrap.StringVar(&configPath, "config", os.Getenv("OVERSEE_COLLECTOR_CONFIG"), "path to the collector's YAML configuration")
+	bootstrap.BoolVar(&checkConfig, "check-config", false, "validate the configuration and exit")
+	collector.DefaultConfig().RegisterFlags(bootstrap)
+	bootstrap.Parse(args)
+
+	config, err := collector.LoadConfig(configPath)
+	if err != nil {
+		return nil, checkConfig, err
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.String("config", configPath, "path to the collector's YAML configuration")
+	flags.Bool("check-config", checkConfig, "validate the configuration and exit")
+	config.RegisterFlags(flags)
+	flags.Parse(args)
+
+	return config, checkConfig, nil
+}
+
 func main() {
-	sqlitePersistence, err := sqlite.NewSQLitePersistence("test.db")
+	config, checkConfig, err := loadConfig(os.Args[1:])
 
 	if err != nil {
-		log.Fatal("Failed to initialize persistence")
+		log.Fatal(err)
+	}
+
+	if err = collector.Check(config); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
 
-	collectorApi := audit.NewLogsIngestionAPI(sqlitePersistence)
+	fmt.Println("Effective configuration:")
+	config.Print(os.Stdout)
 
-	go func() {
-		searchService := audit.NewSearchService(sqlitePersistence)
-		gqlServer := graphql.NewGraphqlAPIServer(searchService)
-		gqlServer.Start()
-	}()
+	if checkConfig {
+		fmt.Println("Configuration OK")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	collectorApi.Serve()
+	if err = collector.Run(ctx, config); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"oversee/agent"
+)
+
+const bufferKeyUsage = `usage: console buffer-key [flags] <action>
+
+actions:
+  generate   print a new hex encoded key
+  verify     check that the buffer opens with -key-file, or is plaintext without it
+  rotate     re-encrypt the buffer from -key-file to -new-key-file, the agent must be stopped
+
+The spill directory of an agent with the spill overflow policy shares the
+key of its buffer, pass it with -spill-dir to verify or rotate it as well.
+
+An empty -key-file or -new-key-file stands for a plaintext buffer. The key
+defaults to OVERSEE_AGENT_BUFFER_ENCRYPTION_KEY when -key-file is not set.
+
+Rotating only re-encrypts the keys protecting the data, not the data itself.
+Logs buffered in plaintext stay unencrypted on disk after rotating to a key,
+until they are flushed; rotating to no key leaves them encrypted with keys
+anyone can read.
+
+flags:`
+
+// loadBufferKey loads the key of an agent's buffer from keyFile, or from the
+// environment like the agent does when keyFile is not set.
+func loadBufferKey(keyFile string) ([]byte, error) {
+	if keyFile != "" {
+		return agent.LoadEncryptionKey(agent.EncryptionConfig{KeyFile: keyFile})
+	}
+
+	return agent.LoadEncryptionKey(agent.EncryptionConfig{
+		Key: os.Getenv("OVERSEE_AGENT_BUFFER_ENCRYPTION_KEY"),
+	})
+}
+
+// runBufferKey manages the key encrypting an agent's buffer.
+func runBufferKey(args []string) int {
+	flags := flag.NewFlagSet("buffer-key", flag.ExitOnError)
+	bufferDir := flags.String("buffer-dir", "/tmp/trail", "directory of the agent's local buffer")
+	keyFile := flags.String("key-file", "", "path to the hex encoded key the buffer is encrypted with")
+	newKeyFile := flags.String("new-key-file", "", "path to the hex encoded key to encrypt the buffer with")
+	spillDir := flags.String("spill-dir", "", "spill directory of the agent's buffer, if any")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, bufferKeyUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	dirs := []string{*bufferDir}
+	if *spillDir != "" {
+		dirs = append(dirs, *spillDir)
+	}
+
+	switch flags.Arg(0) {
+	case "generate":
+		key, err := agent.GenerateEncryptionKey()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		fmt.Println(key)
+		return 0
+	case "verify":
+		key, err := loadBufferKey(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		failed := false
+		for _, dir := range dirs {
+			if err = agent.VerifyEncryptionKey(dir, key); err != nil {
+				fmt.Fprintf(os.Stderr, "FAILED  %s: %v\n", dir, err)
+				failed = true
+				continue
+			}
+
+			fmt.Printf("OK      %s opens with the given key\n", dir)
+		}
+
+		if failed {
+			return 1
+		}
+		return 0
+	case "rotate":
+		oldKey, err := loadBufferKey(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		newKey, err := agent.LoadEncryptionKey(agent.EncryptionConfig{KeyFile: *newKeyFile})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		if err = agent.RotateEncryptionKey(dirs, oldKey, newKey); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		for _, dir := range dirs {
+			fmt.Println("Rotated the key of", dir)
+		}
+		return 0
+	default:
+		flags.Usage()
+		return 2
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"oversee/agent"
+	"oversee/core"
+	"text/tabwriter"
+	"time"
+)
+
+const deadLettersUsage = `usage: console deadletters [flags] <action> [args]
+
+actions:
+  list                 list the dead letters
+  show <key>           print a dead letter and its log
+  edit <key> <file>    replace the log of a dead letter with the JSON log in file, - for stdin
+  replay <key>...      put dead letters back in the buffer, all of them with -all
+  purge <key>...       delete dead letters, all of them with -all
+
+The agent using the buffer must be stopped. Replayed logs are sent on its
+next start.
+
+flags:`
+
+// runDeadLetters manages the logs an agent set aside after the collector
+// kept rejecting them.
+func runDeadLetters(args []string) int {
+	flags := flag.NewFlagSet("deadletters", flag.ExitOnError)
+	bufferDir := flags.String("buffer-dir", "/tmp/trail", "directory of the agent's local buffer")
+	keyFile := flags.String("key-file", "", "path to the hex encoded key the buffer is encrypted with")
+	all := flags.Bool("all", false, "replay or purge every dead letter")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, deadLettersUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	action, rest := flags.Arg(0), flags.Args()[1:]
+
+	key, err := loadBufferKey(*keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	deadLetters, err := agent.OpenDeadLetters(*bufferDir, key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer deadLetters.Close()
+
+	switch {
+	case action == "list" && len(rest) == 0:
+		err = listDeadLetters(deadLetters)
+	case action == "show" && len(rest) == 1:
+		err = showDeadLetter(deadLetters, rest[0])
+	case action == "edit" && len(rest) == 2:
+		err = editDeadLetter(deadLetters, rest[0], rest[1])
+	case action == "replay" && (len(rest) > 0 || *all):
+		err = eachDeadLetter(deadLetters, rest, *all, "Replayed", deadLetters.Replay)
+	case action == "purge" && (len(rest) > 0 || *all):
+		err = eachDeadLetter(deadLetters, rest, *all, "Purged", deadLetters.Purge)
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func listDeadLetters(deadLetters *agent.DeadLetters) error {
+	list, err := deadLetters.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tATTEMPTS\tDEAD-LETTERED AT\tREASON")
+	for _, deadLetter := range list {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", deadLetter.Key, deadLetter.Attempts, deadLetter.DeadLetteredAt.Format(time.RFC3339), deadLetter.Reason)
+	}
+
+	return w.Flush()
+}
+
+func showDeadLetter(deadLetters *agent.DeadLetters, key string) error {
+	deadLetter, err := deadLetters.Get(key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Key:              %s\n", deadLetter.Key)
+	fmt.Printf("Attempts:         %d\n", deadLetter.Attempts)
+	fmt.Printf("Dead-lettered at: %s\n", deadLetter.DeadLetteredAt.Format(time.RFC3339))
+	fmt.Printf("Reason:           %s\n", deadLetter.Reason)
+	if deadLetter.Code != 0 {
+		fmt.Printf("Code:             %d\n", deadLetter.Code)
+	}
+	fmt.Println()
+
+	// A log that cannot be decoded is shown as stored so it can be fixed
+	// by hand.
+	var log bytes.Buffer
+	if err = json.Indent(&log, deadLetter.Value, "", "  "); err != nil {
+		fmt.Printf("%q\n", deadLetter.Value)
+		return nil
+	}
+
+	fmt.Println(log.String())
+	return nil
+}
+
+func editDeadLetter(deadLetters *agent.DeadLetters, key string, path string) error {
+	var data []byte
+	var err error
+
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log, err := core.DecodeLog(data)
+	if err != nil {
+		return err
+	}
+
+	if err = deadLetters.Edit(key, log); err != nil {
+		return err
+	}
+
+	fmt.Println("Edited", key)
+	return nil
+}
+
+func eachDeadLetter(deadLetters *agent.DeadLetters, keys []string, all bool, done string, action func(key string) error) error {
+	if all {
+		list, err := deadLetters.List()
+		if err != nil {
+			return err
+		}
+
+		keys = keys[:0]
+		for _, deadLetter := range list {
+			keys = append(keys, deadLetter.Key)
+		}
+	}
+
+	for _, key := range keys {
+		if err := action(key); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		fmt.Println(done, key)
+	}
+
+	return nil
+}
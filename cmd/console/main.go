@@ -1,5 +1,63 @@
 package main
 
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	description string
+	run         func(args []string) int
+}
+
+var commands = map[string]command{
+	"buffer-key": {
+		description: "generate, verify or rotate the key encrypting an agent's buffer",
+		run:         runBufferKey,
+	},
+	"deadletters": {
+		description: "list, inspect, edit, replay or purge an agent's dead-lettered logs",
+		run:         runDeadLetters,
+	},
+	"migrate": {
+		description: "apply, or list with -dry-run, the pending schema migrations of a collector's store",
+		run:         runMigrate,
+	},
+	"verify": {
+		description: "verify the integrity hash chain of every service",
+		run:         runVerify,
+	},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: console <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].description)
+	}
+}
+
 func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
 
+	os.Exit(cmd.run(os.Args[2:]))
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"oversee/collector"
+	"oversee/collector/persistence"
+	"strings"
+)
+
+const migrateUsage = `usage: console migrate [flags]
+
+Applies the pending schema migrations of the collector's store, each in its
+own transaction. The store is read from the collector configuration, which
+-storage and -dsn override.
+
+flags:`
+
+// runMigrate brings the schema of a collector's store up to date, or with
+// -dry-run only lists the migrations that would be applied.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := flags.String("config", os.Getenv("OVERSEE_COLLECTOR_CONFIG"), "path to the collector's YAML configuration")
+	backend := flags.String("storage", "", "storage backend, overriding the configuration")
+	dsn := flags.String("dsn", "", "data source name of the storage backend, overriding the configuration")
+	dryRun := flags.Bool("dry-run", false, "list the pending migrations and their SQL without applying them")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	config, err := collector.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if *backend != "" {
+		config.Storage.Backend = *backend
+	}
+	if *dsn != "" {
+		config.Storage.DSN = *dsn
+	}
+
+	store, err := collector.OpenStorage(config.Storage)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	version, err := store.SchemaVersion(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	pending, err := store.PendingMigrations(ctx)
+
+	var tooNew *persistence.SchemaTooNewError
+	if errors.As(err, &tooNew) {
+		fmt.Fprintf(os.Stderr, "FAILED  %v, upgrade the collector\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	fmt.Printf("Schema version %d, %d migrations pending\n", version, len(pending))
+
+	if *dryRun {
+		for _, migration := range pending {
+			fmt.Printf("\n-- %d: %s\n%s\n", migration.Version, migration.Description, strings.TrimSpace(migration.SQL))
+		}
+		return 0
+	}
+
+	applied, err := store.Migrate(ctx)
+
+	for _, migration := range applied {
+		fmt.Printf("OK      %d: %s\n", migration.Version, migration.Description)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED  %v\n", err)
+		return 1
+	}
+
+	return 0
+}
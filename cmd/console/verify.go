@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"oversee/collector/integrity"
+	"oversee/collector/persistence"
+	"oversee/collector/persistence/sqlite"
+)
+
+// runVerify checks every record of the logs table and exits with 1 when any
+// chain is broken, or the schema is not the one the console knows, so it can
+// be used from scheduled jobs.
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	dbPath := flags.String("db", "test.db", "path to the collector SQLite database")
+	verbose := flags.Bool("v", false, "print every issue instead of only the first per service")
+	flags.Parse(args)
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot open database:", err)
+		return 2
+	}
+
+	// The database is opened read-only, an audit must not change what it
+	// audits.
+	p, err := sqlite.OpenSQLitePersistence("file:" + *dbPath + "?mode=ro")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	ok := true
+
+	version, err := p.SchemaVersion(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	pending, err := p.PendingMigrations(ctx)
+
+	var tooNew *persistence.SchemaTooNewError
+	switch {
+	case errors.As(err, &tooNew):
+		fmt.Printf("SCHEMA  %v, upgrade the console\n", err)
+		ok = false
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	case len(pending) > 0:
+		fmt.Printf("SCHEMA  version %d, %d migrations pending, apply them with console migrate\n", version, len(pending))
+		ok = false
+	}
+
+	if version < sqlite.ChainSchemaVersion {
+		fmt.Printf("SCHEMA  version %d has no hash chains to verify\n", version)
+		return 1
+	}
+
+	if version < sqlite.ChainHeadSchemaVersion {
+		fmt.Printf("SCHEMA  version %d records no chain heads to verify the chains end at\n", version)
+		return 1
+	}
+
+	report, err := integrity.Verify(ctx, p)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "verification failed:", err)
+		return 2
+	}
+
+	for _, service := range report.Services {
+		if service.FirstBrokenLink == nil {
+			fmt.Printf("OK      %s (%d records)\n", service.ServiceName, service.Records)
+			continue
+		}
+
+		fmt.Printf("BROKEN  %s (%d records, %d issues)\n", service.ServiceName, service.Records, len(service.Issues))
+		fmt.Printf("        first broken link: %s\n", service.FirstBrokenLink)
+
+		if *verbose {
+			for _, issue := range service.Issues {
+				fmt.Printf("        %s\n", issue)
+			}
+		}
+	}
+
+	if !report.OK() || !ok {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"oversee/collector/persistence/sqlite"
+	"oversee/core"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newVerifyTestDB returns the path of a collector database holding a chain
+// of five logs.
+func newVerifyTestDB(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "collector.db")
+
+	p, err := sqlite.NewSQLitePersistence(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for range 5 {
+		_, err = p.PersistLog(context.Background(), &core.Log{
+			ID:                uuid.New(),
+			Timestamp:         time.Now().UTC(),
+			ServiceName:       "billing",
+			Operation:         "invoice.pay",
+			ActorId:           "actor",
+			ActorType:         "user",
+			AffectedResources: []string{"invoice/1"},
+			Metadata:          map[string]any{"amount": 12.5},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string
+		want   int
+	}{
+		{"intact chain", "", 0},
+		{"edited log", "UPDATE logs SET operation = 'invoice.void' WHERE chain_sequence = 3", 1},
+		{"deleted log", "DELETE FROM logs WHERE chain_sequence = 3", 1},
+		{"deleted newest logs", "DELETE FROM logs WHERE chain_sequence > 3", 1},
+		{"deleted every log", "DELETE FROM logs", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := newVerifyTestDB(t)
+
+			if test.tamper != "" {
+				db, err := sql.Open("sqlite3", path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				_, err = db.Exec(test.tamper)
+				db.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if code := runVerify([]string{"-db", path}); code != test.want {
+				t.Errorf("verify exited with %d, want %d", code, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"oversee/collector/persistence"
+	"oversee/core"
+	"oversee/pkg/tlsconfig"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const heartbeatSignatureContext = "oversee-heartbeat-v1"
+
+type AgentStatus string
+
+const (
+	// AgentStatusOnline is an agent whose last heartbeat is recent.
+	AgentStatusOnline AgentStatus = "online"
+	// AgentStatusSilent is an agent that stopped sending heartbeats without
+	// announcing it was shutting down.
+	AgentStatusSilent AgentStatus = "silent"
+	// AgentStatusStopped is an agent that announced it was shutting down.
+	AgentStatusStopped AgentStatus = "stopped"
+)
+
+// RegisteredAgent is an agent of the registry along with its status.
+type RegisteredAgent struct {
+	*persistence.AgentRegistration
+	Status AgentStatus
+}
+
+// AgentRegistry lists the agents that registered with the collector.
+type AgentRegistry struct {
+	persistence persistence.Persistence
+	// staleAfter is how long an agent may go without a heartbeat before it
+	// is considered silent.
+	staleAfter time.Duration
+}
+
+func NewAgentRegistry(p persistence.Persistence, staleAfter time.Duration) *AgentRegistry {
+	return &AgentRegistry{
+		persistence: p,
+		staleAfter:  staleAfter,
+	}
+}
+
+func (r *AgentRegistry) ListAgents(ctx context.Context) ([]*RegisteredAgent, error) {
+	registrations, err := r.persistence.ListAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	agents := []*RegisteredAgent{}
+
+	for _, registration := range registrations {
+		agents = append(agents, &RegisteredAgent{
+			AgentRegistration: registration,
+			Status:            r.status(registration, now),
+		})
+	}
+
+	return agents, nil
+}
+
+func (r *AgentRegistry) status(registration *persistence.AgentRegistration, now time.Time) AgentStatus {
+	switch {
+	case registration.Stopped:
+		return AgentStatusStopped
+	case now.Sub(registration.LastSeen) > r.staleAfter:
+		return AgentStatusSilent
+	default:
+		return AgentStatusOnline
+	}
+}
+
+// heartbeatPayload is the message an agent signs for a heartbeat: a context
+// string and the deterministic encoding of the heartbeat without its
+// signature.
+func heartbeatPayload(request *RegisterAgentRequest) ([]byte, error) {
+	unsigned := proto.Clone(request).(*RegisterAgentRequest)
+	unsigned.Signature = nil
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(heartbeatSignatureContext+"\n"), b...), nil
+}
+
+// SignHeartbeat sets the signature of the heartbeat, which must not change
+// afterwards.
+func SignHeartbeat(key ed25519.PrivateKey, request *RegisterAgentRequest) error {
+	payload, err := heartbeatPayload(request)
+	if err != nil {
+		return err
+	}
+
+	request.Signature = ed25519.Sign(key, payload)
+
+	return nil
+}
+
+// RegisterAgent implements CollectorServer. When the agent authenticated
+// with a client certificate, it may only register under its own identity.
+// When signatures are enforced, an agent that did not must sign its
+// heartbeat with the key it is trusted with.
+func (c LogsIngestionAPI) RegisterAgent(ctx context.Context, request *RegisterAgentRequest) (*RegisterAgentReply, error) {
+	agentID := agentIdentity(ctx, request.AgentId)
+
+	identity, identified := tlsconfig.PeerIdentity(ctx)
+	if identified && agentID != identity {
+		fmt.Println("Rejecting registration from", identity, "claiming to be", agentID)
+		return nil, status.Error(codes.Unauthenticated, core.ErrorAgentIdentityMismatch.Error())
+	}
+
+	if agentID == "" {
+		return nil, status.Error(codes.InvalidArgument, "agent_id required")
+	}
+
+	if c.trustedAgents != nil && !identified {
+		if err := c.trustedAgents.VerifyHeartbeat(request); err != nil {
+			fmt.Println("Rejecting registration from", agentID, err)
+
+			if coreErr, ok := err.(*core.Error); ok {
+				return nil, status.Error(codes.Unauthenticated, coreErr.Error())
+			}
+			return nil, err
+		}
+	}
+
+	firstSeen, err := c.persistence.RegisterAgent(ctx, &persistence.AgentRegistration{
+		AgentID:            agentID,
+		Application:        request.Application,
+		ApplicationVersion: request.ApplicationVersion,
+		AgentVersion:       request.AgentVersion,
+		Hostname:           request.GetHost().GetHostname(),
+		OS:                 request.GetHost().GetOs(),
+		Arch:               request.GetHost().GetArch(),
+		PID:                request.GetHost().GetPid(),
+		StartedAt:          request.StartedAt.AsTime(),
+		BufferedRecords:    request.GetBuffer().GetRecords(),
+		BufferedBytes:      request.GetBuffer().GetBytes(),
+		SpilledRecords:     request.GetBuffer().GetSpilledRecords(),
+		Stopped:            request.Stopping,
+		LastSeen:           time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegisterAgentReply{FirstSeen: timestamppb.New(firstSeen)}, nil
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"oversee/collector/persistence"
 	"oversee/core"
+	"oversee/pkg/lifecycle"
+	"oversee/pkg/tlsconfig"
+	"time"
 
 	"github.com/google/uuid"
 	grpc "google.golang.org/grpc"
@@ -17,6 +21,56 @@ import (
 type LogsIngestionAPI struct {
 	UnimplementedCollectorServer
 	persistence persistence.Persistence
+	// trustedAgents is nil when signatures are not enforced.
+	trustedAgents *TrustedAgents
+	listenAddress string
+	tls           *tlsconfig.Config
+}
+
+// errorStatus returns the status of a call failed with a core error,
+// carrying the error as a detail so that the agent can tell its code.
+func errorStatus(code codes.Code, err *core.Error) error {
+	st, detailsErr := status.New(code, err.Error()).WithDetails(&Error{
+		Message: err.Message,
+		Code:    int32(err.Code),
+	})
+
+	if detailsErr != nil {
+		return status.Error(code, err.Error())
+	}
+
+	return st.Err()
+}
+
+// verifySignature rejects logs that were not signed by a trusted agent. When
+// the agent authenticated with a client certificate, its identity stands in
+// for a missing agent ID and must match the one it claims otherwise.
+func (c LogsIngestionAPI) verifySignature(ctx context.Context, agentID string, logs []*core.Log, signature []byte) error {
+	if identity, ok := tlsconfig.PeerIdentity(ctx); ok {
+		if agentID == "" {
+			agentID = identity
+		}
+
+		if agentID != identity {
+			fmt.Println("Rejecting logs from", identity, "claiming to be", agentID)
+			return errorStatus(codes.Unauthenticated, core.ErrorAgentIdentityMismatch)
+		}
+	}
+
+	if c.trustedAgents == nil {
+		return nil
+	}
+
+	if err := c.trustedAgents.Verify(agentID, logs, signature); err != nil {
+		fmt.Println("Rejecting logs from", agentID, err)
+
+		if coreErr, ok := err.(*core.Error); ok {
+			return errorStatus(codes.Unauthenticated, coreErr)
+		}
+		return err
+	}
+
+	return nil
 }
 
 // BatchPersistLog implements CollectorServer.
@@ -28,18 +82,38 @@ func (c LogsIngestionAPI) BatchPersistLog(ctx context.Context, request *BatchPer
 		logs = append(logs, LogEntityFromAPILog(log))
 	}
 
-	results, err := c.persistence.BatchPersistLog(ctx, logs)
+	if err := c.verifySignature(ctx, request.AgentId, logs, request.Signature); err != nil {
+		return nil, err
+	}
+
+	results, err := c.persistence.BatchPersistLog(ctx, logs, request.AllOrNothing)
 
 	if err != nil {
 		return nil, err
 	}
 
 	replies := []*PersistLogReply{}
+	sequences := map[string]uint64{}
+	received := []int64{}
+
+	for _, log := range request.Logs {
+		sequences[log.Id] = log.AgentSequence
+	}
+
+	for i, result := range results {
+		reply := LogPersistenceResultToPersistLogReply(result)
+		// A log whose ID is not a UUID is only known by the ID it was sent
+		// with.
+		reply.Id = request.Logs[i].Id
+		replies = append(replies, reply)
 
-	for _, result := range results {
-		replies = append(replies, LogPersistenceResultToPersistLogReply(result))
+		if sequence := sequences[result.ID]; sequence > 0 && (result.Success || (result.Reason != nil && result.Reason.Code == core.ErrorCodeAlreadyPersistedLog)) {
+			received = append(received, int64(sequence))
+		}
 	}
 
+	c.trackSequences(ctx, agentIdentity(ctx, request.AgentId), received)
+
 	v, _ := json.Marshal(results)
 	fmt.Println(replies, string(v))
 
@@ -97,8 +171,16 @@ func (c LogsIngestionAPI) PersistLog(ctx context.Context, request *PersistLogReq
 
 	log := LogEntityFromAPILog(request.Log)
 
+	if err := c.verifySignature(ctx, request.AgentId, []*core.Log{log}, request.Signature); err != nil {
+		return nil, err
+	}
+
 	result, err := c.persistence.PersistLog(ctx, log)
 
+	if (err == nil || err == core.ErrorAlreadyPersistedLog) && request.Log.AgentSequence > 0 {
+		c.trackSequences(ctx, agentIdentity(ctx, request.AgentId), []int64{int64(request.Log.AgentSequence)})
+	}
+
 	if err != nil {
 		if err == core.ErrorAlreadyPersistedLog {
 			return &PersistLogReply{
@@ -106,6 +188,9 @@ func (c LogsIngestionAPI) PersistLog(ctx context.Context, request *PersistLogReq
 				Success: true,
 			}, status.Error(codes.AlreadyExists, err.Error())
 		}
+		if coreErr, ok := err.(*core.Error); ok && coreErr.Code == core.ErrorCodeInvalidLog {
+			return nil, errorStatus(codes.InvalidArgument, coreErr)
+		}
 		return &PersistLogReply{
 			Id:      request.Log.Id,
 			Success: false,
@@ -115,28 +200,113 @@ func (c LogsIngestionAPI) PersistLog(ctx context.Context, request *PersistLogReq
 	return LogPersistenceResultToPersistLogReply(result), nil
 }
 
-func (a *LogsIngestionAPI) Serve() error {
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", 4093))
-	fmt.Println("Starting collector API on port 4093")
+// StreamLogs implements CollectorServer. Each log is verified and persisted
+// as it arrives and acknowledged with its own reply. The stream ends on the
+// first log that fails signature verification.
+func (c LogsIngestionAPI) StreamLogs(stream grpc.BidiStreamingServer[StreamLogsRequest, PersistLogReply]) error {
+	ctx := stream.Context()
+
+	for {
+		request, err := stream.Recv()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if request.Log == nil {
+			return status.Error(codes.InvalidArgument, "log required")
+		}
+
+		log := LogEntityFromAPILog(request.Log)
+
+		if err = c.verifySignature(ctx, request.AgentId, []*core.Log{log}, request.Signature); err != nil {
+			return err
+		}
+
+		reply := &PersistLogReply{Id: request.Log.Id}
+		_, err = c.persistence.PersistLog(ctx, log)
+
+		if (err == nil || err == core.ErrorAlreadyPersistedLog) && request.Log.AgentSequence > 0 {
+			c.trackSequences(ctx, agentIdentity(ctx, request.AgentId), []int64{int64(request.Log.AgentSequence)})
+		}
+
+		switch {
+		case err == nil:
+			reply.Success = true
+		case err == core.ErrorAlreadyPersistedLog:
+			reply.Reason = &Error{
+				Message: core.ErrorAlreadyPersistedLog.Message,
+				Code:    int32(core.ErrorAlreadyPersistedLog.Code),
+			}
+		default:
+			fmt.Println("Failed to persist", request.Log.Id, err)
+
+			// Errors other than an invalid log come from the storage, and
+			// the agent keeps the log to send it again.
+			reason := core.ErrorWithMessage(core.ErrorCodeStorageFailure, err.Error())
+			if coreErr, ok := err.(*core.Error); ok {
+				reason = coreErr
+			}
+			reply.Reason = &Error{Message: reason.Message, Code: int32(reason.Code)}
+		}
+
+		if err = stream.Send(reply); err != nil {
+			return err
+		}
+	}
+}
+
+// Serve serves the API until ctx is done, then lets in-flight RPCs finish
+// within shutdownTimeout.
+func (a *LogsIngestionAPI) Serve(ctx context.Context, shutdownTimeout time.Duration) error {
+	creds, err := a.tls.ServerCredentials()
+
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", a.listenAddress)
+	fmt.Println("Starting collector API on", a.listenAddress)
 
 	if err != nil {
 		return err
 	}
 
-	s := grpc.NewServer()
+	s := grpc.NewServer(grpc.Creds(creds))
 
 	RegisterCollectorServer(s, a)
 
-	err = s.Serve(listener)
-	if err != nil {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(listener)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
 		return err
 	}
 
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	lifecycle.StopGRPCServer(shutdownCtx, s)
+
 	return nil
 }
 
-func NewLogsIngestionAPI(persistence persistence.Persistence) *LogsIngestionAPI {
+// NewLogsIngestionAPI creates the collector API. When trustedAgents is nil,
+// logs are accepted without checking their signature, and when tls is nil
+// the API is served in plaintext.
+func NewLogsIngestionAPI(persistence persistence.Persistence, listenAddress string, tls *tlsconfig.Config, trustedAgents *TrustedAgents) *LogsIngestionAPI {
 	return &LogsIngestionAPI{
-		persistence: persistence,
+		persistence:   persistence,
+		trustedAgents: trustedAgents,
+		listenAddress: listenAddress,
+		tls:           tls,
 	}
 }
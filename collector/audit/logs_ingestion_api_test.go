@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"oversee/collector/persistence/sqlite"
+	"oversee/core"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// peerContext returns the context of a call from a client that
+// authenticated with a certificate for identity.
+func peerContext(identity string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: identity}}
+
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		},
+	})
+}
+
+func TestVerifySignatureWithPeerIdentity(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := []*core.Log{{
+		ID:          uuid.New(),
+		Timestamp:   time.Now().UTC(),
+		ServiceName: "billing",
+		Operation:   "invoice.pay",
+		Metadata:    map[string]any{},
+	}}
+
+	signature, err := core.SignLogs(private, "agent-1", logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := LogsIngestionAPI{trustedAgents: NewTrustedAgents(map[string]ed25519.PublicKey{"agent-1": public})}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		agentID string
+		want    codes.Code
+	}{
+		{"identity matching the agent", peerContext("agent-1"), "agent-1", codes.OK},
+		{"identity standing in for the agent", peerContext("agent-1"), "", codes.OK},
+		{"identity of another agent", peerContext("agent-2"), "agent-1", codes.Unauthenticated},
+		{"no identity", context.Background(), "agent-1", codes.OK},
+		{"no identity nor agent", context.Background(), "", codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := api.verifySignature(test.ctx, test.agentID, logs, signature)
+			if code := status.Code(err); code != test.want {
+				t.Errorf("verifySignature() = %v, want code %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestPeerIdentityMismatchWithoutTrustedAgents(t *testing.T) {
+	api := LogsIngestionAPI{}
+
+	err := api.verifySignature(peerContext("agent-2"), "agent-1", nil, nil)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("verifySignature() = %v, want code %v", err, codes.Unauthenticated)
+	}
+
+	if err = api.verifySignature(peerContext("agent-1"), "", nil, nil); err != nil {
+		t.Errorf("verifySignature() = %v for an identified agent", err)
+	}
+}
+
+func TestRegisterAgentAuthenticatesHeartbeat(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := sqlite.NewSQLitePersistence(filepath.Join(t.TempDir(), "collector.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	api := NewLogsIngestionAPI(p, "", nil, NewTrustedAgents(map[string]ed25519.PublicKey{"agent-1": public}))
+
+	heartbeat := func(key ed25519.PrivateKey) *RegisterAgentRequest {
+		request := &RegisterAgentRequest{
+			AgentId:     "agent-1",
+			Application: "billing",
+			Host:        &HostInfo{Hostname: "host-1", Pid: 42},
+			StartedAt:   timestamppb.Now(),
+		}
+
+		if key != nil {
+			if err := SignHeartbeat(key, request); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return request
+	}
+
+	tampered := heartbeat(private)
+	tampered.Stopping = true
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		request *RegisterAgentRequest
+		want    codes.Code
+	}{
+		{"signed by the agent", context.Background(), heartbeat(private), codes.OK},
+		{"unsigned", context.Background(), heartbeat(nil), codes.Unauthenticated},
+		{"signed with another key", context.Background(), heartbeat(otherPrivate), codes.Unauthenticated},
+		{"changed after signing", context.Background(), tampered, codes.Unauthenticated},
+		{"unsigned from the identified agent", peerContext("agent-1"), heartbeat(nil), codes.OK},
+		{"signed from another identified agent", peerContext("agent-2"), heartbeat(private), codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := api.RegisterAgent(test.ctx, test.request)
+			if code := status.Code(err); code != test.want {
+				t.Errorf("RegisterAgent() = %v, want code %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestPersistLogRejectsInvalidLogWithItsCode(t *testing.T) {
+	p, err := sqlite.NewSQLitePersistence(filepath.Join(t.TempDir(), "collector.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	api := NewLogsIngestionAPI(p, "", nil, nil)
+
+	_, err = api.PersistLog(context.Background(), &PersistLogRequest{Log: &Log{
+		Id:          "not-a-uuid",
+		Timestamp:   timestamppb.Now(),
+		ServiceName: "billing",
+		Operation:   "invoice.pay",
+	}})
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("PersistLog() = %v, want code %v", err, codes.InvalidArgument)
+	}
+
+	for _, detail := range st.Details() {
+		if reason, ok := detail.(*Error); ok && reason.Code == int32(core.ErrorCodeInvalidLog) {
+			return
+		}
+	}
+
+	t.Errorf("PersistLog() details are %v, want the code %d", st.Details(), core.ErrorCodeInvalidLog)
+}
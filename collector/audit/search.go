@@ -20,6 +20,10 @@ func (s *SearchService) SearchLogs(ctx context.Context, query persistence.Search
 	return s.persistence.SearchLogs(ctx, query)
 }
 
+func (s *SearchService) ListAgentSequences(ctx context.Context) ([]*persistence.AgentSequence, error) {
+	return s.persistence.ListAgentSequences(ctx)
+}
+
 func (s *SearchService) ListLogs(ctx context.Context, cursorTimestamp int64, cursorID string) ([]*core.Log, error) {
 	return s.persistence.ListLogs(ctx, cursorTimestamp, cursorID)
 }
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"oversee/collector/persistence"
+	"oversee/core"
+	"oversee/pkg/tlsconfig"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CollectorServiceName is the service of the logs the collector writes to
+// the audit trail itself.
+const CollectorServiceName = "oversee.collector"
+
+// sequenceMetrics holds, per agent, the contiguous and highest sequence
+// numbers received and the number of logs missing in between. It is not
+// published with expvar, whose handler would also serve the command line
+// and its credentials, but by MetricsHandler.
+var sequenceMetrics = new(expvar.Map).Init()
+
+// MetricsHandler serves the collector's metrics as JSON, in the format of
+// expvar.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\n%q: %s\n}\n", "agent_sequences", sequenceMetrics.String())
+	})
+}
+
+// agentIdentity returns the agent a request comes from, which is the peer
+// certificate's identity when the request does not name one.
+func agentIdentity(ctx context.Context, agentID string) string {
+	if agentID != "" {
+		return agentID
+	}
+
+	identity, _ := tlsconfig.PeerIdentity(ctx)
+	return identity
+}
+
+// trackSequences records the sequence numbers of logs the collector now
+// has, and writes a log to the audit trail for every gap still open once
+// the agent's sequence numbers moved persistence.SequenceGapGrace past it.
+// Logs from unidentified agents, or without sequence number, are not
+// tracked.
+func (c LogsIngestionAPI) trackSequences(ctx context.Context, agentID string, sequences []int64) {
+	if agentID == "" || len(sequences) == 0 {
+		return
+	}
+
+	state, gaps, err := c.persistence.RecordAgentSequences(ctx, agentID, sequences)
+	if err != nil {
+		fmt.Println("Failed to record sequences of", agentID, err)
+		return
+	}
+
+	metrics := new(expvar.Map).Init()
+	metrics.Add("contiguous", state.Contiguous)
+	metrics.Add("highest", state.Highest)
+	metrics.Add("missing", state.Missing())
+	sequenceMetrics.Set(agentID, metrics)
+
+	for _, gap := range gaps {
+		fmt.Printf("Logs %d to %d from %s are missing\n", gap.From, gap.To, agentID)
+
+		if err = c.flagSequenceGap(ctx, agentID, gap); err != nil {
+			fmt.Println("Failed to flag sequence gap of", agentID, err)
+		}
+	}
+}
+
+func (c LogsIngestionAPI) flagSequenceGap(ctx context.Context, agentID string, gap persistence.SequenceGap) error {
+	_, err := c.persistence.PersistLog(ctx, &core.Log{
+		ID:                uuid.New(),
+		Timestamp:         time.Now().UTC(),
+		ServiceName:       CollectorServiceName,
+		Operation:         "agent.sequence_gap",
+		ActorId:           agentID,
+		ActorType:         "agent",
+		AffectedResources: []string{"agent/" + agentID},
+		Metadata: map[string]any{
+			"from":    gap.From,
+			"to":      gap.To,
+			"missing": gap.To - gap.From + 1,
+		},
+	})
+
+	return err
+}
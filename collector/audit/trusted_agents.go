@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"oversee/core"
+	"oversee/pkg/signing"
+)
+
+// TrustedAgents is the registry of agents allowed to dispatch logs to the
+// collector, keyed by agent ID.
+type TrustedAgents struct {
+	keys map[string]ed25519.PublicKey
+}
+
+func NewTrustedAgents(keys map[string]ed25519.PublicKey) *TrustedAgents {
+	return &TrustedAgents{
+		keys: keys,
+	}
+}
+
+// LoadTrustedAgents loads the public keys of a directory holding one
+// <agent-id>.pub file per agent.
+func LoadTrustedAgents(dir string) (*TrustedAgents, error) {
+	keys, err := signing.LoadPublicKeys(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTrustedAgents(keys), nil
+}
+
+func (t *TrustedAgents) Len() int {
+	return len(t.keys)
+}
+
+// Trusts reports whether the agent has a key in the registry.
+func (t *TrustedAgents) Trusts(agentID string) bool {
+	_, ok := t.keys[agentID]
+	return ok
+}
+
+// Verify checks that the logs were signed by a trusted agent.
+func (t *TrustedAgents) Verify(agentID string, logs []*core.Log, signature []byte) error {
+	if agentID == "" || len(signature) == 0 {
+		return core.ErrorUnsignedRequest
+	}
+
+	key, ok := t.keys[agentID]
+	if !ok {
+		return core.ErrorUnknownAgent
+	}
+
+	return core.VerifyLogs(key, agentID, logs, signature)
+}
+
+// VerifyHeartbeat checks that the heartbeat was signed by the trusted agent
+// it comes from.
+func (t *TrustedAgents) VerifyHeartbeat(request *RegisterAgentRequest) error {
+	if request.AgentId == "" || len(request.Signature) == 0 {
+		return core.ErrorUnsignedRequest
+	}
+
+	key, ok := t.keys[request.AgentId]
+	if !ok {
+		return core.ErrorUnknownAgent
+	}
+
+	payload, err := heartbeatPayload(request)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key, payload, request.Signature) {
+		return core.ErrorInvalidSignature
+	}
+
+	return nil
+}
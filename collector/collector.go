@@ -1,3 +1,201 @@
 package collector
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"oversee/collector/audit"
+	"oversee/collector/graphql"
+	"oversee/collector/persistence"
+	"oversee/collector/persistence/postgres"
+	"oversee/collector/persistence/sqlite"
+)
 
+// OpenStorage opens the storage backend selected by the configuration
+// without touching its schema.
+func OpenStorage(config StorageConfig) (persistence.Persistence, error) {
+	switch config.Backend {
+	case "sqlite":
+		return sqlite.OpenSQLitePersistence(config.DSN)
+	case "postgres":
+		return postgres.OpenPostgresPersistence(config.DSN)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.Backend)
+	}
+}
+
+// OpenPersistence opens the storage backend selected by the configuration
+// and makes sure its schema is the one the collector knows, migrating it
+// when allowed to.
+func OpenPersistence(ctx context.Context, config StorageConfig) (persistence.Persistence, error) {
+	store, err := OpenStorage(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := store.PendingMigrations(ctx)
+	if err == nil && len(pending) > 0 {
+		if config.AutoMigrate {
+			err = migrate(ctx, store)
+		} else {
+			err = fmt.Errorf("%d schema migrations are pending, apply them with console migrate", len(pending))
+		}
+	}
+
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func migrate(ctx context.Context, store persistence.Persistence) error {
+	applied, err := store.Migrate(ctx)
+
+	for _, migration := range applied {
+		fmt.Printf("Applied migration %d: %s\n", migration.Version, migration.Description)
+	}
+
+	return err
+}
+
+// LoadTrustedAgents loads the agents allowed to send logs, returning nil
+// when signatures are not checked.
+func LoadTrustedAgents(config GRPCConfig) (*audit.TrustedAgents, error) {
+	if config.TrustedAgentsDir == "" {
+		return nil, nil
+	}
+
+	trustedAgents, err := audit.LoadTrustedAgents(config.TrustedAgentsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trusted agents: %w", err)
+	}
+
+	return trustedAgents, nil
+}
+
+// Check validates the configuration and loads the files it points to, so
+// that unreadable certificates and keys are caught before starting.
+func Check(config *Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := config.GRPC.TLS.ServerCredentials(); err != nil {
+		return fmt.Errorf("grpc.tls: %w", err)
+	}
+
+	_, err := LoadTrustedAgents(config.GRPC)
+	return err
+}
+
+// newMetricsServer serves the collector's metrics at /debug/vars.
+func newMetricsServer(listenAddress string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", audit.MetricsHandler())
+
+	return &http.Server{Addr: listenAddress, Handler: mux}
+}
+
+// Run serves the ingestion and GraphQL APIs, and the metrics when
+// configured, and enforces retention until ctx is done or any server fails,
+// then shuts everything down within the configured timeout.
+func Run(ctx context.Context, config *Config) error {
+	trustedAgents, err := LoadTrustedAgents(config.GRPC)
+	if err != nil {
+		return err
+	}
+
+	if trustedAgents != nil {
+		fmt.Printf("Loaded %d trusted agents\n", trustedAgents.Len())
+	} else {
+		fmt.Println("No trusted agents configured, log signatures will not be checked")
+	}
+
+	store, err := OpenPersistence(ctx, config.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to initialize persistence: %w", err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	collectorApi := audit.NewLogsIngestionAPI(store, config.GRPC.ListenAddress, &config.GRPC.TLS, trustedAgents)
+	gqlServer := graphql.NewGraphqlAPIServer(audit.NewSearchService(store), audit.NewAgentRegistry(store, config.Agents.StaleAfter), config.HTTP.ListenAddress, config.HTTP.CORSOrigins)
+
+	retentionDone := make(chan struct{})
+	go func() {
+		EnforceRetention(ctx, store, config.Retention)
+		close(retentionDone)
+	}()
+
+	grpcErr := make(chan error, 1)
+	go func() {
+		grpcErr <- collectorApi.Serve(ctx, config.ShutdownTimeout)
+	}()
+
+	httpErr := make(chan error, 1)
+	go func() {
+		httpErr <- gqlServer.Start()
+	}()
+
+	// metricsErr stays nil, and never ready, when metrics are not served.
+	var metricsServer *http.Server
+	var metricsErr chan error
+	if config.Metrics.ListenAddress != "" {
+		metricsServer = newMetricsServer(config.Metrics.ListenAddress)
+		metricsErr = make(chan error, 1)
+		go func() {
+			metricsErr <- metricsServer.ListenAndServe()
+		}()
+	}
+
+	var errs []error
+	grpcDone, httpDone, metricsDone := false, false, false
+
+	select {
+	case <-ctx.Done():
+	case err := <-grpcErr:
+		errs = append(errs, err)
+		grpcDone = true
+	case err := <-httpErr:
+		errs = append(errs, err)
+		httpDone = true
+	case err := <-metricsErr:
+		errs = append(errs, err)
+		metricsDone = true
+	}
+
+	fmt.Println("Shutting down collector")
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancelShutdown()
+
+	errs = append(errs, gqlServer.Shutdown(shutdownCtx))
+	if metricsServer != nil {
+		errs = append(errs, metricsServer.Shutdown(shutdownCtx))
+	}
+
+	if !grpcDone {
+		errs = append(errs, <-grpcErr)
+	}
+	if !httpDone {
+		errs = append(errs, <-httpErr)
+	}
+	if metricsServer != nil && !metricsDone {
+		errs = append(errs, <-metricsErr)
+	}
+	<-retentionDone
+
+	for i, err := range errs {
+		if errors.Is(err, http.ErrServerClosed) {
+			errs[i] = nil
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -3,9 +3,39 @@
 package model
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 )
 
+type Agent struct {
+	AgentID            string      `json:"agent_id"`
+	Application        string      `json:"application"`
+	ApplicationVersion string      `json:"application_version"`
+	AgentVersion       string      `json:"agent_version"`
+	Hostname           string      `json:"hostname"`
+	Os                 string      `json:"os"`
+	Arch               string      `json:"arch"`
+	Pid                int         `json:"pid"`
+	StartedAt          time.Time   `json:"started_at"`
+	BufferedRecords    int         `json:"buffered_records"`
+	BufferedBytes      int         `json:"buffered_bytes"`
+	SpilledRecords     int         `json:"spilled_records"`
+	FirstSeen          time.Time   `json:"first_seen"`
+	LastSeen           time.Time   `json:"last_seen"`
+	Status             AgentStatus `json:"status"`
+}
+
+type AgentSequence struct {
+	AgentID    string         `json:"agent_id"`
+	Contiguous int            `json:"contiguous"`
+	Highest    int            `json:"highest"`
+	Missing    int            `json:"missing"`
+	Gaps       []*SequenceGap `json:"gaps"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
 type AuditLogEvent struct {
 	ID                string         `json:"id"`
 	Timestamp         time.Time      `json:"timestamp"`
@@ -32,6 +62,96 @@ type SearchQuery struct {
 	ActorID           *string        `json:"actorID,omitempty"`
 	ActorType         *string        `json:"actorType,omitempty"`
 	AffectedResources []*string      `json:"affectedResources,omitempty"`
+	ResourceMatch     *ResourceMatch `json:"resourceMatch,omitempty"`
 	Metadata          map[string]any `json:"metadata,omitempty"`
 	Cursor            *Cursor        `json:"cursor,omitempty"`
 }
+
+type SequenceGap struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type AgentStatus string
+
+const (
+	AgentStatusOnline  AgentStatus = "ONLINE"
+	AgentStatusSilent  AgentStatus = "SILENT"
+	AgentStatusStopped AgentStatus = "STOPPED"
+)
+
+var AllAgentStatus = []AgentStatus{
+	AgentStatusOnline,
+	AgentStatusSilent,
+	AgentStatusStopped,
+}
+
+func (e AgentStatus) IsValid() bool {
+	switch e {
+	case AgentStatusOnline, AgentStatusSilent, AgentStatusStopped:
+		return true
+	}
+	return false
+}
+
+func (e AgentStatus) String() string {
+	return string(e)
+}
+
+func (e *AgentStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AgentStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AgentStatus", str)
+	}
+	return nil
+}
+
+func (e AgentStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ResourceMatch string
+
+const (
+	ResourceMatchAny ResourceMatch = "ANY"
+	ResourceMatchAll ResourceMatch = "ALL"
+)
+
+var AllResourceMatch = []ResourceMatch{
+	ResourceMatchAny,
+	ResourceMatchAll,
+}
+
+func (e ResourceMatch) IsValid() bool {
+	switch e {
+	case ResourceMatchAny, ResourceMatchAll:
+		return true
+	}
+	return false
+}
+
+func (e ResourceMatch) String() string {
+	return string(e)
+}
+
+func (e *ResourceMatch) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ResourceMatch(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ResourceMatch", str)
+	}
+	return nil
+}
+
+func (e ResourceMatch) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
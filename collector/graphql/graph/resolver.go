@@ -10,4 +10,5 @@ import "oversee/collector/audit"
 
 type Resolver struct {
 	SearchService *audit.SearchService
+	AgentRegistry *audit.AgentRegistry
 }
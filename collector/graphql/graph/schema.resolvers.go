@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"oversee/collector/graphql/graph/model"
 	"oversee/collector/persistence"
+	"strings"
 )
 
 // ListAuditLogs is the resolver for the listAuditLogs field.
@@ -33,13 +34,15 @@ func (r *queryResolver) ListAuditLogs(ctx context.Context, cursor *model.Cursor)
 	for _, log := range logs {
 		fmt.Println(log)
 		auditLogEvents = append(auditLogEvents, &model.AuditLogEvent{
-			ID:          log.ID.String(),
-			Timestamp:   log.Timestamp,
-			ServiceName: log.ServiceName,
-			Operation:   log.Operation,
-			ActorID:     log.ActorId,
-			ActorType:   log.ActorType,
-			Metadata:    log.Metadata,
+			ID:                log.ID.String(),
+			Timestamp:         log.Timestamp,
+			ServiceName:       log.ServiceName,
+			Operation:         log.Operation,
+			ActorID:           log.ActorId,
+			ActorType:         log.ActorType,
+			AffectedResources: log.AffectedResources,
+			Metadata:          log.Metadata,
+			IntegrityHash:     log.IntegrityHash,
 		})
 	}
 
@@ -62,9 +65,15 @@ func (r *queryResolver) SearchAuditLogs(ctx context.Context, query model.SearchQ
 		persistenceQuery.ActorID = *query.ActorID
 	}
 
-	//if query.AffectedResources != nil {
-	//	persistenceQuery.AffectedResources = query.AffectedResources
-	//}
+	for _, resource := range query.AffectedResources {
+		if resource != nil {
+			persistenceQuery.AffectedResources = append(persistenceQuery.AffectedResources, *resource)
+		}
+	}
+
+	if query.ResourceMatch != nil && *query.ResourceMatch == model.ResourceMatchAll {
+		persistenceQuery.ResourceMatch = persistence.ResourceMatchAll
+	}
 
 	if query.Cursor != nil {
 		persistenceQuery.CursorID = query.Cursor.ID
@@ -81,19 +90,79 @@ func (r *queryResolver) SearchAuditLogs(ctx context.Context, query model.SearchQ
 	var auditLogEvents []*model.AuditLogEvent
 	for _, log := range logs {
 		auditLogEvents = append(auditLogEvents, &model.AuditLogEvent{
-			ID:          log.ID.String(),
-			Timestamp:   log.Timestamp,
-			ServiceName: log.ServiceName,
-			Operation:   log.Operation,
-			ActorID:     log.ActorId,
-			ActorType:   log.ActorType,
-			Metadata:    log.Metadata,
+			ID:                log.ID.String(),
+			Timestamp:         log.Timestamp,
+			ServiceName:       log.ServiceName,
+			Operation:         log.Operation,
+			ActorID:           log.ActorId,
+			ActorType:         log.ActorType,
+			AffectedResources: log.AffectedResources,
+			Metadata:          log.Metadata,
+			IntegrityHash:     log.IntegrityHash,
 		})
 	}
 
 	return auditLogEvents, nil
 }
 
+// AgentSequences is the resolver for the agentSequences field.
+func (r *queryResolver) AgentSequences(ctx context.Context) ([]*model.AgentSequence, error) {
+	states, err := r.SearchService.ListAgentSequences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	agentSequences := []*model.AgentSequence{}
+	for _, state := range states {
+		gaps := []*model.SequenceGap{}
+		for _, gap := range state.Gaps {
+			gaps = append(gaps, &model.SequenceGap{From: int(gap.From), To: int(gap.To)})
+		}
+
+		agentSequences = append(agentSequences, &model.AgentSequence{
+			AgentID:    state.AgentID,
+			Contiguous: int(state.Contiguous),
+			Highest:    int(state.Highest),
+			Missing:    int(state.Missing()),
+			Gaps:       gaps,
+			UpdatedAt:  state.UpdatedAt,
+		})
+	}
+
+	return agentSequences, nil
+}
+
+// Agents is the resolver for the agents field.
+func (r *queryResolver) Agents(ctx context.Context) ([]*model.Agent, error) {
+	registered, err := r.AgentRegistry.ListAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	agents := []*model.Agent{}
+	for _, agent := range registered {
+		agents = append(agents, &model.Agent{
+			AgentID:            agent.AgentID,
+			Application:        agent.Application,
+			ApplicationVersion: agent.ApplicationVersion,
+			AgentVersion:       agent.AgentVersion,
+			Hostname:           agent.Hostname,
+			Os:                 agent.OS,
+			Arch:               agent.Arch,
+			Pid:                int(agent.PID),
+			StartedAt:          agent.StartedAt,
+			BufferedRecords:    int(agent.BufferedRecords),
+			BufferedBytes:      int(agent.BufferedBytes),
+			SpilledRecords:     int(agent.SpilledRecords),
+			FirstSeen:          agent.FirstSeen,
+			LastSeen:           agent.LastSeen,
+			Status:             model.AgentStatus(strings.ToUpper(string(agent.Status))),
+		})
+	}
+
+	return agents, nil
+}
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
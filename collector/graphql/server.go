@@ -4,7 +4,6 @@ import (
 	"context"
 	"log"
 	"net/http"
-	"os"
 	"oversee/collector/audit"
 	"oversee/collector/graphql/graph"
 
@@ -18,27 +17,17 @@ import (
 	"github.com/rs/cors"
 )
 
-const defaultPort = "8080"
-
 type GraphqlAPIServer struct {
 	searchService *audit.SearchService
 	server        *http.Server
 }
 
-func NewGraphqlAPIServer(searchService *audit.SearchService) *GraphqlAPIServer {
-	return &GraphqlAPIServer{
-		searchService: searchService,
-	}
-}
-
-func (g *GraphqlAPIServer) Start() error {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
-	}
-
+// NewGraphqlAPIServer creates the GraphQL API, allowing browser requests
+// from corsOrigins only. An origin of * allows any.
+func NewGraphqlAPIServer(searchService *audit.SearchService, agentRegistry *audit.AgentRegistry, listenAddress string, corsOrigins []string) *GraphqlAPIServer {
 	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{
-		SearchService: g.searchService,
+		SearchService: searchService,
+		AgentRegistry: agentRegistry,
 	}}))
 
 	srv.AddTransport(transport.Options{})
@@ -52,15 +41,25 @@ func (g *GraphqlAPIServer) Start() error {
 		Cache: lru.New[string](100),
 	})
 
-	c := cors.Default()
+	c := cors.New(cors.Options{
+		AllowedOrigins: corsOrigins,
+	})
 	handler := c.Handler(srv)
 
-	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", handler)
+	mux := http.NewServeMux()
+	mux.Handle("/", playground.Handler("GraphQL playground", "/query"))
+	mux.Handle("/query", handler)
 
-	g.server = &http.Server{Addr: ":" + port}
+	return &GraphqlAPIServer{
+		searchService: searchService,
+		server:        &http.Server{Addr: listenAddress, Handler: mux},
+	}
+}
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
+// Start serves the API until Shutdown is called, returning
+// http.ErrServerClosed then.
+func (g *GraphqlAPIServer) Start() error {
+	log.Printf("connect to http://%s/ for GraphQL playground", g.server.Addr)
 	return g.server.ListenAndServe()
 }
 
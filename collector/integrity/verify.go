@@ -0,0 +1,212 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"oversee/collector/persistence"
+	"oversee/core"
+)
+
+const pageSize = 500
+
+type IssueKind string
+
+const (
+	// IssueHashMismatch means the stored hash does not match the hash
+	// recomputed from the record's content, i.e. the record was edited.
+	IssueHashMismatch IssueKind = "hash_mismatch"
+	// IssueBrokenLink means the record does not reference the hash of the
+	// record preceding it in the chain.
+	IssueBrokenLink IssueKind = "broken_link"
+	// IssueMissing means one or more sequence numbers are absent from the
+	// chain, i.e. records were deleted.
+	IssueMissing IssueKind = "missing"
+	// IssueOutOfOrder means a record was stored after a record with a higher
+	// sequence number.
+	IssueOutOfOrder IssueKind = "out_of_order"
+	// IssueTruncated means the chain ends before its recorded head, i.e. its
+	// newest records were deleted.
+	IssueTruncated IssueKind = "truncated"
+	// IssueHeadMismatch means the chain ends past its recorded head, or on a
+	// record other than the one recorded.
+	IssueHeadMismatch IssueKind = "head_mismatch"
+)
+
+type Issue struct {
+	Kind        IssueKind
+	ServiceName string
+	Sequence    int64
+	LogID       string
+	Detail      string
+}
+
+func (i Issue) String() string {
+	if i.LogID == "" {
+		return fmt.Sprintf("[%s] %s sequence %d: %s", i.Kind, i.ServiceName, i.Sequence, i.Detail)
+	}
+	return fmt.Sprintf("[%s] %s sequence %d (log %s): %s", i.Kind, i.ServiceName, i.Sequence, i.LogID, i.Detail)
+}
+
+type ServiceReport struct {
+	ServiceName string
+	Records     int
+	// FirstBrokenLink is the earliest issue found in the chain, if any.
+	// Every record after it can no longer be trusted.
+	FirstBrokenLink *Issue
+	Issues          []Issue
+}
+
+func (r *ServiceReport) addIssue(issue Issue) {
+	r.Issues = append(r.Issues, issue)
+	if r.FirstBrokenLink == nil {
+		r.FirstBrokenLink = &issue
+	}
+}
+
+type Report struct {
+	Services []*ServiceReport
+}
+
+// OK reports whether every chain verified without issues.
+func (r *Report) OK() bool {
+	for _, service := range r.Services {
+		if len(service.Issues) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify walks every service's hash chain and checks each record's integrity
+// hash and its linkage to the record before it.
+func Verify(ctx context.Context, p persistence.Persistence) (*Report, error) {
+	services, err := p.ListChainServices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	report := &Report{}
+	for _, service := range services {
+		serviceReport, err := VerifyService(ctx, p, service)
+		if err != nil {
+			return nil, err
+		}
+		report.Services = append(report.Services, serviceReport)
+	}
+
+	return report, nil
+}
+
+// VerifyService verifies the hash chain of a single service, and that it ends
+// at the head recorded for it.
+func VerifyService(ctx context.Context, p persistence.Persistence, serviceName string) (*ServiceReport, error) {
+	report := &ServiceReport{ServiceName: serviceName}
+
+	checkpoint, err := p.GetChainCheckpoint(ctx, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint of %s: %w", serviceName, err)
+	}
+
+	// A chain whose start was purged by retention continues from its
+	// checkpoint.
+	var position int64
+	highestSequence := checkpoint.Sequence
+	previousHash := checkpoint.IntegrityHash
+
+	for {
+		records, err := p.ListChain(ctx, serviceName, position, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chain of %s: %w", serviceName, err)
+		}
+
+		for _, record := range records {
+			position = record.Position
+			report.Records++
+
+			issue := Issue{
+				ServiceName: serviceName,
+				Sequence:    record.Sequence,
+				LogID:       record.Log.ID.String(),
+			}
+
+			switch {
+			case record.Sequence <= highestSequence:
+				issue.Kind = IssueOutOfOrder
+				issue.Detail = fmt.Sprintf("stored after sequence %d", highestSequence)
+				report.addIssue(issue)
+			case record.Sequence > highestSequence+1:
+				report.addIssue(Issue{
+					Kind:        IssueMissing,
+					ServiceName: serviceName,
+					Sequence:    highestSequence + 1,
+					Detail:      missingDetail(highestSequence+1, record.Sequence-1),
+				})
+			}
+
+			if record.Sequence > highestSequence {
+				highestSequence = record.Sequence
+			}
+
+			if record.PreviousHash != previousHash {
+				issue.Kind = IssueBrokenLink
+				issue.Detail = fmt.Sprintf("references previous hash %q, expected %q", record.PreviousHash, previousHash)
+				report.addIssue(issue)
+			}
+
+			expectedHash, err := core.ChainHash(record.PreviousHash, record.Log)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash log %s: %w", record.Log.ID, err)
+			}
+
+			if record.Log.IntegrityHash != expectedHash {
+				issue.Kind = IssueHashMismatch
+				issue.Detail = fmt.Sprintf("stored hash %q, computed %q", record.Log.IntegrityHash, expectedHash)
+				report.addIssue(issue)
+			}
+
+			previousHash = record.Log.IntegrityHash
+		}
+
+		if len(records) < pageSize {
+			break
+		}
+	}
+
+	head, err := p.GetChainHead(ctx, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read head of %s: %w", serviceName, err)
+	}
+
+	switch {
+	case head.Sequence > highestSequence:
+		report.addIssue(Issue{
+			Kind:        IssueTruncated,
+			ServiceName: serviceName,
+			Sequence:    highestSequence + 1,
+			Detail:      missingDetail(highestSequence+1, head.Sequence) + " from the end of the chain",
+		})
+	case head.Sequence < highestSequence:
+		report.addIssue(Issue{
+			Kind:        IssueHeadMismatch,
+			ServiceName: serviceName,
+			Sequence:    highestSequence,
+			Detail:      fmt.Sprintf("the chain goes past its recorded head at sequence %d", head.Sequence),
+		})
+	case head.IntegrityHash != previousHash:
+		report.addIssue(Issue{
+			Kind:        IssueHeadMismatch,
+			ServiceName: serviceName,
+			Sequence:    highestSequence,
+			Detail:      fmt.Sprintf("ends on hash %q, the recorded head has %q", previousHash, head.IntegrityHash),
+		})
+	}
+
+	return report, nil
+}
+
+func missingDetail(from, to int64) string {
+	if from == to {
+		return fmt.Sprintf("sequence %d is missing", from)
+	}
+	return fmt.Sprintf("sequences %d to %d are missing", from, to)
+}
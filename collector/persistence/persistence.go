@@ -2,7 +2,10 @@ package persistence
 
 import (
 	"context"
+	"fmt"
 	"oversee/core"
+	"strings"
+	"time"
 )
 
 type LogPersistenceResult struct {
@@ -11,22 +14,248 @@ type LogPersistenceResult struct {
 	Reason  *core.Error
 }
 
-// TODO: Add support to supabase persistence
+// ChainRecord is a persisted log together with its position in its
+// service's hash chain.
+type ChainRecord struct {
+	// Position is the storage insertion order of the record, usable as a
+	// cursor for ListChain.
+	Position     int64
+	Sequence     int64
+	PreviousHash string
+	Log          *core.Log
+}
+
+// ChainCheckpoint is the last record removed from the start of a service's
+// hash chain by retention. The remaining chain continues from it.
+type ChainCheckpoint struct {
+	Sequence      int64
+	IntegrityHash string
+}
+
+// SequenceGap is a range of sequence numbers, inclusive, that an agent gave
+// to logs the collector never received.
+type SequenceGap struct {
+	From int64
+	To   int64
+}
+
+// AgentSequence tracks the sequence numbers of the logs received from an
+// agent.
+type AgentSequence struct {
+	AgentID string
+	// Contiguous is the sequence number up to which every log was received.
+	Contiguous int64
+	Highest    int64
+	Gaps       []SequenceGap
+	UpdatedAt  time.Time
+}
+
+// Missing returns how many logs the gaps add up to.
+func (a *AgentSequence) Missing() int64 {
+	var missing int64
+	for _, gap := range a.Gaps {
+		missing += gap.To - gap.From + 1
+	}
+	return missing
+}
+
+// SequenceGapGrace is how far the highest sequence number received from an
+// agent must move past a missing one before it is reported. Logs sent again
+// after a failure, or dispatched concurrently, arrive after newer ones and
+// fill their gap within it.
+const SequenceGapGrace = 1000
+
+// OverdueSequenceGaps returns the parts of gaps the grace window moved past
+// when the highest sequence number received went from previousHighest to
+// highest. Every missing number is returned once, by the call moving past
+// it.
+func OverdueSequenceGaps(gaps []SequenceGap, previousHighest int64, highest int64) []SequenceGap {
+	from := previousHighest - SequenceGapGrace + 1
+	to := highest - SequenceGapGrace
+
+	overdue := []SequenceGap{}
+	for _, gap := range gaps {
+		gap.From = max(gap.From, from)
+		gap.To = min(gap.To, to)
+
+		if gap.From <= gap.To {
+			overdue = append(overdue, gap)
+		}
+	}
+
+	return overdue
+}
+
+// FindSequenceGaps returns the ranges missing between contiguous and the
+// sequence numbers received past it, which must be sorted.
+func FindSequenceGaps(contiguous int64, received []int64) []SequenceGap {
+	gaps := []SequenceGap{}
+	expected := contiguous + 1
+
+	for _, sequence := range received {
+		if sequence > expected {
+			gaps = append(gaps, SequenceGap{From: expected, To: sequence - 1})
+		}
+		expected = sequence + 1
+	}
+
+	return gaps
+}
+
+// AgentRegistration is what an agent last reported about itself to the
+// collector.
+type AgentRegistration struct {
+	AgentID            string
+	Application        string
+	ApplicationVersion string
+	AgentVersion       string
+	Hostname           string
+	OS                 string
+	Arch               string
+	PID                int64
+	StartedAt          time.Time
+	BufferedRecords    int64
+	BufferedBytes      int64
+	SpilledRecords     int64
+	// Stopped is set when the agent announced it was shutting down.
+	Stopped   bool
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Migration is a step in the schema of a store. Each one is applied in a
+// transaction along with the record of its version.
+type Migration struct {
+	Version     int
+	Description string
+	SQL         string
+}
+
+// SchemaTooNewError is returned when a store was migrated by a newer
+// collector, whose schema this one cannot safely use.
+type SchemaTooNewError struct {
+	Version int
+	Known   int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("schema version %d is newer than the latest known version %d", e.Version, e.Known)
+}
+
+// PendingMigrations returns the migrations past version, which must be
+// ordered by version.
+func PendingMigrations(migrations []Migration, version int) ([]Migration, error) {
+	known := 0
+	if len(migrations) > 0 {
+		known = migrations[len(migrations)-1].Version
+	}
+
+	if version > known {
+		return nil, &SchemaTooNewError{Version: version, Known: known}
+	}
+
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// ResourceMatch tells how the affected resources of a search select logs.
+type ResourceMatch int
+
+const (
+	// ResourceMatchAny selects the logs touching any of the resources.
+	ResourceMatchAny ResourceMatch = iota
+	// ResourceMatchAll selects the logs touching all of the resources.
+	ResourceMatchAll
+)
+
+// ResourcePrefix tells whether a searched resource is a prefix, such as
+// "org/123/project/*", which matches every resource starting with
+// "org/123/project/".
+func ResourcePrefix(resource string) (string, bool) {
+	return strings.CutSuffix(resource, "*")
+}
+
+// PrefixEnd returns the smallest string greater than every string starting
+// with prefix, when the byte order of strings is used, or "" when there is
+// none.
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for len(end) > 0 && end[len(end)-1] == 0xff {
+		end = end[:len(end)-1]
+	}
+
+	if len(end) == 0 {
+		return ""
+	}
+
+	end[len(end)-1]++
+	return string(end)
+}
 
 type SearchQuery struct {
-	ServiceName       string
-	Operation         string
-	ActorID           string
-	ActorType         string
+	ServiceName string
+	Operation   string
+	ActorID     string
+	ActorType   string
+	// AffectedResources are resource IDs or prefixes, see ResourcePrefix,
+	// matched according to ResourceMatch.
 	AffectedResources []string
+	ResourceMatch     ResourceMatch
 	Metadata          map[string]any
 	CursorTimestamp   int64
 	CursorID          string
 }
 
+// Persistence is a store of audit logs, implemented by the sqlite and
+// postgres packages. Supabase databases are used through the latter, with
+// their connection string.
 type Persistence interface {
 	PersistLog(ctx context.Context, log *core.Log) (*LogPersistenceResult, error)
-	BatchPersistLog(ctx context.Context, log []*core.Log) ([]*LogPersistenceResult, error)
+	// BatchPersistLog persists the logs in a single transaction, giving the
+	// reason of every log that was not persisted: already persisted,
+	// invalid or a storage failure. With allOrNothing, an invalid log keeps
+	// every other one from being persisted as well.
+	BatchPersistLog(ctx context.Context, logs []*core.Log, allOrNothing bool) ([]*LogPersistenceResult, error)
 	ListLogs(ctx context.Context, cursorTimestamp int64, cursorID string) ([]*core.Log, error)
 	SearchLogs(ctx context.Context, query SearchQuery) ([]*core.Log, error)
+	// ListChainServices returns the services with a hash chain, including
+	// those whose logs were all removed.
+	ListChainServices(ctx context.Context) ([]string, error)
+	// ListChain returns up to limit records of a service's hash chain in
+	// insertion order, starting after the given position.
+	ListChain(ctx context.Context, serviceName string, afterPosition int64, limit int) ([]*ChainRecord, error)
+	// GetChainCheckpoint returns the checkpoint of a service, with a zero
+	// sequence when its chain was never purged.
+	GetChainCheckpoint(ctx context.Context, serviceName string) (*ChainCheckpoint, error)
+	// GetChainHead returns the sequence and integrity hash recorded for the
+	// last log appended to a service's chain, with a zero sequence when none
+	// was. Logs deleted from the end of the chain leave it behind.
+	GetChainHead(ctx context.Context, serviceName string) (*ChainCheckpoint, error)
+	// PurgeLogs removes, for every service, the longest prefix of its chain
+	// made only of logs older than before, and records a checkpoint.
+	PurgeLogs(ctx context.Context, before time.Time) (int64, error)
+	// RecordAgentSequences records the sequence numbers of logs received
+	// from an agent. It returns the agent's state and the missing sequence
+	// numbers the highest one received moved SequenceGapGrace past.
+	RecordAgentSequences(ctx context.Context, agentID string, sequences []int64) (*AgentSequence, []SequenceGap, error)
+	ListAgentSequences(ctx context.Context) ([]*AgentSequence, error)
+	// RegisterAgent creates or replaces the registration of an agent,
+	// keeping the time it was first seen, which it returns.
+	RegisterAgent(ctx context.Context, registration *AgentRegistration) (time.Time, error)
+	ListAgents(ctx context.Context) ([]*AgentRegistration, error)
+	// SchemaVersion returns the version of the last migration applied to
+	// the store, 0 when there is none.
+	SchemaVersion(ctx context.Context) (int, error)
+	// PendingMigrations returns the migrations not applied yet, failing
+	// with a SchemaTooNewError when the store is ahead of them.
+	PendingMigrations(ctx context.Context) ([]Migration, error)
+	// Migrate applies the pending migrations in order and returns them.
+	Migrate(ctx context.Context) ([]Migration, error)
+	Close() error
 }
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"oversee/collector/persistence"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (p *PostgresPersistence) RecordAgentSequences(ctx context.Context, agentID string, sequences []int64) (*persistence.AgentSequence, []persistence.SequenceGap, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	state := &persistence.AgentSequence{AgentID: agentID, UpdatedAt: time.Now().UTC()}
+
+	// The row of the agent is created first so that it can be locked
+	// against concurrent requests from the same agent.
+	_, err = tx.Exec(ctx, "INSERT INTO agent_sequences (agent_id, contiguous, highest, updated_at) VALUES ($1, 0, 0, $2) ON CONFLICT (agent_id) DO NOTHING", agentID, state.UpdatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create agent sequence: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, "SELECT contiguous, highest FROM agent_sequences WHERE agent_id = $1 FOR UPDATE", agentID).
+		Scan(&state.Contiguous, &state.Highest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read agent sequence: %w", err)
+	}
+
+	previousHighest := state.Highest
+
+	pending := []int64{}
+	for _, sequence := range sequences {
+		if sequence > state.Contiguous {
+			pending = append(pending, sequence)
+			state.Highest = max(state.Highest, sequence)
+		}
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO agent_sequences_received (agent_id, sequence) SELECT $1, unnest($2::BIGINT[]) ON CONFLICT DO NOTHING", agentID, pending)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to record agent sequence: %w", err)
+	}
+
+	received, err := receivedSequences(ctx, tx, agentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for len(received) > 0 && received[0] == state.Contiguous+1 {
+		state.Contiguous++
+		received = received[1:]
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM agent_sequences_received WHERE agent_id = $1 AND sequence <= $2", agentID, state.Contiguous)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to trim agent sequences: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE agent_sequences SET contiguous = $2, highest = $3, updated_at = $4 WHERE agent_id = $1",
+		agentID, state.Contiguous, state.Highest, state.UpdatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update agent sequence: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	state.Gaps = persistence.FindSequenceGaps(state.Contiguous, received)
+
+	return state, persistence.OverdueSequenceGaps(state.Gaps, previousHighest, state.Highest), nil
+}
+
+func (p *PostgresPersistence) ListAgentSequences(ctx context.Context) ([]*persistence.AgentSequence, error) {
+	rows, err := p.pool.Query(ctx, "SELECT agent_id, contiguous, highest, updated_at FROM agent_sequences ORDER BY agent_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agent sequences: %w", err)
+	}
+
+	states, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*persistence.AgentSequence, error) {
+		state := &persistence.AgentSequence{}
+		err := row.Scan(&state.AgentID, &state.Contiguous, &state.Highest, &state.UpdatedAt)
+		state.UpdatedAt = state.UpdatedAt.UTC()
+		return state, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agent sequences: %w", err)
+	}
+
+	for _, state := range states {
+		received, err := receivedSequences(ctx, p.pool, state.AgentID)
+		if err != nil {
+			return nil, err
+		}
+
+		state.Gaps = persistence.FindSequenceGaps(state.Contiguous, received)
+	}
+
+	return states, nil
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// receivedSequences returns the sequence numbers received from an agent
+// past its contiguous one, in order.
+func receivedSequences(ctx context.Context, q querier, agentID string) ([]int64, error) {
+	rows, err := q.Query(ctx, "SELECT sequence FROM agent_sequences_received WHERE agent_id = $1 ORDER BY sequence", agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent sequences: %w", err)
+	}
+
+	received, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent sequences: %w", err)
+	}
+
+	return received, nil
+}
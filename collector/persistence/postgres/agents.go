@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"oversee/collector/persistence"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (p *PostgresPersistence) RegisterAgent(ctx context.Context, registration *persistence.AgentRegistration) (time.Time, error) {
+	var firstSeen time.Time
+
+	err := p.pool.QueryRow(ctx, `
+INSERT INTO agents (agent_id, application, application_version, agent_version, hostname, os, arch, pid, started_at, buffered_records, buffered_bytes, spilled_records, stopped, first_seen, last_seen)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $14)
+ON CONFLICT (agent_id) DO UPDATE SET
+	application = excluded.application,
+	application_version = excluded.application_version,
+	agent_version = excluded.agent_version,
+	hostname = excluded.hostname,
+	os = excluded.os,
+	arch = excluded.arch,
+	pid = excluded.pid,
+	started_at = excluded.started_at,
+	buffered_records = excluded.buffered_records,
+	buffered_bytes = excluded.buffered_bytes,
+	spilled_records = excluded.spilled_records,
+	stopped = excluded.stopped,
+	last_seen = excluded.last_seen
+RETURNING first_seen`,
+		registration.AgentID,
+		registration.Application,
+		registration.ApplicationVersion,
+		registration.AgentVersion,
+		registration.Hostname,
+		registration.OS,
+		registration.Arch,
+		registration.PID,
+		registration.StartedAt,
+		registration.BufferedRecords,
+		registration.BufferedBytes,
+		registration.SpilledRecords,
+		registration.Stopped,
+		registration.LastSeen,
+	).Scan(&firstSeen)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	return firstSeen.UTC(), nil
+}
+
+func (p *PostgresPersistence) ListAgents(ctx context.Context) ([]*persistence.AgentRegistration, error) {
+	rows, err := p.pool.Query(ctx, `
+SELECT agent_id, application, application_version, agent_version, hostname, os, arch, pid, started_at, buffered_records, buffered_bytes, spilled_records, stopped, first_seen, last_seen
+FROM agents ORDER BY agent_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	agents, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*persistence.AgentRegistration, error) {
+		agent := &persistence.AgentRegistration{}
+
+		err := row.Scan(
+			&agent.AgentID,
+			&agent.Application,
+			&agent.ApplicationVersion,
+			&agent.AgentVersion,
+			&agent.Hostname,
+			&agent.OS,
+			&agent.Arch,
+			&agent.PID,
+			&agent.StartedAt,
+			&agent.BufferedRecords,
+			&agent.BufferedBytes,
+			&agent.SpilledRecords,
+			&agent.Stopped,
+			&agent.FirstSeen,
+			&agent.LastSeen,
+		)
+
+		agent.StartedAt = agent.StartedAt.UTC()
+		agent.FirstSeen = agent.FirstSeen.UTC()
+		agent.LastSeen = agent.LastSeen.UTC()
+
+		return agent, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	return agents, nil
+}
@@ -0,0 +1,366 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"oversee/collector/persistence"
+	"oversee/core"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// migrations are the steps of the schema, in order. Applied migrations must
+// never change, new ones go at the end. The first one creates the logs table
+// as it was before the hash chain existed, only if missing, so that older
+// databases are brought up to date by the ones after it.
+var migrations = []persistence.Migration{
+	{
+		Version:     1,
+		Description: "create logs",
+		// Timestamps are kept as unix seconds rather than TIMESTAMPTZ, which
+		// only has microsecond precision, so that logs read back hash to the
+		// same integrity hash.
+		SQL: `
+CREATE TABLE IF NOT EXISTS logs (
+	id UUID PRIMARY KEY,
+	timestamp BIGINT NOT NULL,
+	service_name TEXT NOT NULL,
+	operation TEXT NOT NULL,
+	actor_id TEXT NOT NULL,
+	actor_type TEXT NOT NULL,
+	affected_resources TEXT[] NOT NULL,
+	metadata JSONB NOT NULL,
+	integrity_hash TEXT NOT NULL
+);
+`,
+	},
+	{
+		Version:     2,
+		Description: "add hash chain columns and chain existing logs",
+		// Existing logs are numbered in no particular order by the position
+		// identity, then chained in that order by chainExistingLogs.
+		SQL: `
+ALTER TABLE logs
+	ADD COLUMN IF NOT EXISTS position BIGINT GENERATED ALWAYS AS IDENTITY,
+	ADD COLUMN IF NOT EXISTS timestamp_nanos INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS chain_sequence BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS previous_hash TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		Version:     3,
+		Description: "index hash chains and create chain checkpoints",
+		SQL: `
+CREATE UNIQUE INDEX IF NOT EXISTS logs_chain ON logs (service_name, chain_sequence);
+CREATE INDEX IF NOT EXISTS logs_position ON logs (service_name, position);
+CREATE TABLE IF NOT EXISTS chain_checkpoints (
+	service_name TEXT PRIMARY KEY,
+	sequence BIGINT NOT NULL,
+	integrity_hash TEXT NOT NULL
+);
+`,
+	},
+	{
+		Version:     4,
+		Description: "create agent sequence tables",
+		// Sequence numbers up to the contiguous one are only counted, those
+		// received past it are kept until the ones before them arrive.
+		SQL: `
+CREATE TABLE IF NOT EXISTS agent_sequences (
+	agent_id TEXT PRIMARY KEY,
+	contiguous BIGINT NOT NULL,
+	highest BIGINT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS agent_sequences_received (
+	agent_id TEXT NOT NULL,
+	sequence BIGINT NOT NULL,
+	PRIMARY KEY (agent_id, sequence)
+);
+`,
+	},
+	{
+		Version:     5,
+		Description: "create agent registry",
+		SQL: `
+CREATE TABLE IF NOT EXISTS agents (
+	agent_id TEXT PRIMARY KEY,
+	application TEXT NOT NULL,
+	application_version TEXT NOT NULL,
+	agent_version TEXT NOT NULL,
+	hostname TEXT NOT NULL,
+	os TEXT NOT NULL,
+	arch TEXT NOT NULL,
+	pid BIGINT NOT NULL,
+	started_at TIMESTAMPTZ NOT NULL,
+	buffered_records BIGINT NOT NULL,
+	buffered_bytes BIGINT NOT NULL,
+	spilled_records BIGINT NOT NULL,
+	stopped BOOLEAN NOT NULL,
+	first_seen TIMESTAMPTZ NOT NULL,
+	last_seen TIMESTAMPTZ NOT NULL
+);
+`,
+	},
+	{
+		Version:     6,
+		Description: "index logs for search",
+		// Searches filter on one of the columns and read the newest logs
+		// first, so each index continues with the order of the results.
+		SQL: `
+CREATE INDEX IF NOT EXISTS logs_timestamp ON logs (timestamp, id);
+CREATE INDEX IF NOT EXISTS logs_service_timestamp ON logs (service_name, timestamp, id);
+CREATE INDEX IF NOT EXISTS logs_operation_timestamp ON logs (operation, timestamp, id);
+CREATE INDEX IF NOT EXISTS logs_actor_id_timestamp ON logs (actor_id, timestamp, id);
+CREATE INDEX IF NOT EXISTS logs_actor_type_timestamp ON logs (actor_type, timestamp, id);
+`,
+	},
+	{
+		Version:     7,
+		Description: "create log resources",
+		// The resources of the logs already stored are copied over. The "C"
+		// collation orders resources by bytes, so that prefixes are ranges.
+		SQL: `
+CREATE TABLE IF NOT EXISTS log_resources (
+	log_id UUID NOT NULL REFERENCES logs (id) ON DELETE CASCADE,
+	resource TEXT COLLATE "C" NOT NULL,
+	PRIMARY KEY (resource, log_id)
+);
+CREATE INDEX IF NOT EXISTS log_resources_log ON log_resources (log_id);
+INSERT INTO log_resources (log_id, resource)
+	SELECT logs.id, resources.resource FROM logs, unnest(logs.affected_resources) AS resources (resource)
+	ON CONFLICT DO NOTHING;
+`,
+	},
+	{
+		Version:     8,
+		Description: "create chain heads",
+		// The head of a chain is its last log, or its checkpoint when every
+		// log was purged.
+		SQL: `
+CREATE TABLE IF NOT EXISTS chain_heads (
+	service_name TEXT PRIMARY KEY,
+	sequence BIGINT NOT NULL,
+	integrity_hash TEXT NOT NULL
+);
+INSERT INTO chain_heads (service_name, sequence, integrity_hash)
+	SELECT DISTINCT ON (service_name) service_name, chain_sequence, integrity_hash FROM logs
+	ORDER BY service_name, chain_sequence DESC
+	ON CONFLICT DO NOTHING;
+INSERT INTO chain_heads (service_name, sequence, integrity_hash)
+	SELECT service_name, sequence, integrity_hash FROM chain_checkpoints
+	ON CONFLICT DO NOTHING;
+`,
+	},
+}
+
+// migrationSteps are run after the SQL of the migration with the same
+// version, in its transaction, for what SQL alone cannot do.
+var migrationSteps = map[int]func(ctx context.Context, tx pgx.Tx) error{
+	2: chainExistingLogs,
+}
+
+// chainBackfillSize is how many existing logs are read at once to be
+// chained.
+const chainBackfillSize = 500
+
+const createSchemaVersionTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL
+)
+`
+
+func (p *PostgresPersistence) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, p.pool)
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func schemaVersion(ctx context.Context, q queryRower) (int, error) {
+	var exists bool
+
+	if err := q.QueryRow(ctx, "SELECT to_regclass('schema_version') IS NOT NULL").Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	if err := q.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+func (p *PostgresPersistence) PendingMigrations(ctx context.Context) ([]persistence.Migration, error) {
+	version, err := p.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return persistence.PendingMigrations(migrations, version)
+}
+
+// Migrate applies each pending migration in its own transaction. Collectors
+// migrating the same database at once take turns, and skip the migrations
+// applied while they waited.
+func (p *PostgresPersistence) Migrate(ctx context.Context) ([]persistence.Migration, error) {
+	if _, err := p.pool.Exec(ctx, createSchemaVersionTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create schema version table: %w", err)
+	}
+
+	pending, err := p.PendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []persistence.Migration{}
+	for _, migration := range pending {
+		ok, err := p.applyMigration(ctx, migration)
+		if err != nil {
+			return applied, err
+		}
+
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// applyMigration applies a migration unless another collector did first,
+// reporting whether it did.
+func (p *PostgresPersistence) applyMigration(ctx context.Context, migration persistence.Migration) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('schema_version'))"); err != nil {
+		return false, fmt.Errorf("failed to lock schema: %w", err)
+	}
+
+	version, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+
+	if version >= migration.Version {
+		return false, nil
+	}
+
+	if _, err = tx.Exec(ctx, migration.SQL); err != nil {
+		return false, fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+	}
+
+	if step, ok := migrationSteps[migration.Version]; ok {
+		if err = step(ctx, tx); err != nil {
+			return false, fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO schema_version (version, description, applied_at) VALUES ($1, $2, $3)",
+		migration.Version, migration.Description, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	}
+
+	return true, nil
+}
+
+// chainExistingLogs links the logs stored before the hash chain existed,
+// which have no chain sequence, into the chain of their service in the order
+// they were stored. Their integrity hash, which agents used to fill in, is
+// replaced by their hash in the chain.
+func chainExistingLogs(ctx context.Context, tx pgx.Tx) error {
+	rows, err := tx.Query(ctx, "SELECT DISTINCT service_name FROM logs WHERE chain_sequence = 0")
+	if err != nil {
+		return fmt.Errorf("failed to find unchained logs: %w", err)
+	}
+
+	services, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to find unchained logs: %w", err)
+	}
+
+	for _, service := range services {
+		if err = chainExistingServiceLogs(ctx, tx, service); err != nil {
+			return fmt.Errorf("failed to chain logs of %s: %w", service, err)
+		}
+	}
+
+	return nil
+}
+
+func chainExistingServiceLogs(ctx context.Context, tx pgx.Tx, serviceName string) error {
+	if err := lockChains(ctx, tx, []string{serviceName}); err != nil {
+		return err
+	}
+
+	// Logs chained already, if any, come before the ones being chained.
+	head := &persistence.ChainCheckpoint{}
+	err := tx.QueryRow(ctx,
+		"SELECT chain_sequence, integrity_hash FROM logs WHERE service_name = $1 AND chain_sequence > 0 ORDER BY chain_sequence DESC LIMIT 1",
+		serviceName,
+	).Scan(&head.Sequence, &head.IntegrityHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to read chain head: %w", err)
+	}
+
+	for {
+		rows, err := tx.Query(ctx,
+			"SELECT position, "+logColumns+" FROM logs WHERE service_name = $1 AND chain_sequence = 0 ORDER BY position LIMIT $2",
+			serviceName, chainBackfillSize,
+		)
+		if err != nil {
+			return err
+		}
+
+		var positions []*int64
+		logs, err := scanLogs(rows, func() []any {
+			position := new(int64)
+			positions = append(positions, position)
+			return []any{position}
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, log := range logs {
+			integrityHash, err := core.ChainHash(head.IntegrityHash, log)
+			if err != nil {
+				return fmt.Errorf("failed to compute integrity hash of %s: %w", log.ID, err)
+			}
+
+			_, err = tx.Exec(ctx,
+				"UPDATE logs SET chain_sequence = $1, previous_hash = $2, integrity_hash = $3 WHERE position = $4",
+				head.Sequence+1, head.IntegrityHash, integrityHash, *positions[i],
+			)
+			if err != nil {
+				return err
+			}
+
+			head.Sequence++
+			head.IntegrityHash = integrityHash
+		}
+
+		if len(logs) < chainBackfillSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,673 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"oversee/collector/persistence"
+	"oversee/core"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the SQLSTATE of a unique constraint violation.
+const uniqueViolation = "23505"
+
+const logColumns = "id, timestamp, timestamp_nanos, service_name, operation, actor_id, actor_type, affected_resources, metadata, integrity_hash"
+
+// insertColumns are the columns written for a log, in the order of the
+// rows built by chainLog.
+var insertColumns = []string{
+	"id",
+	"timestamp",
+	"timestamp_nanos",
+	"service_name",
+	"operation",
+	"actor_id",
+	"actor_type",
+	"affected_resources",
+	"metadata",
+	"integrity_hash",
+	"chain_sequence",
+	"previous_hash",
+}
+
+type PostgresPersistence struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresPersistence connects to the database and applies the pending
+// migrations.
+func NewPostgresPersistence(dsn string) (*PostgresPersistence, error) {
+	p, err := OpenPostgresPersistence(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = p.Migrate(context.Background()); err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// OpenPostgresPersistence connects to the database without touching its
+// schema.
+func OpenPostgresPersistence(dsn string) (*PostgresPersistence, error) {
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &PostgresPersistence{pool: pool}, nil
+}
+
+func (p *PostgresPersistence) Close() error {
+	p.pool.Close()
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// scanLogs reads rows selected with logColumns, preceded by the extra
+// columns scanned into the destinations returned by extra.
+func scanLogs(rows pgx.Rows, extra func() []any) ([]*core.Log, error) {
+	defer rows.Close()
+
+	logs := []*core.Log{}
+	for rows.Next() {
+		log := &core.Log{}
+		var unixTimestamp int64
+		var timestampNanos int32
+
+		dest := []any{&log.ID, &unixTimestamp, &timestampNanos, &log.ServiceName, &log.Operation, &log.ActorId, &log.ActorType, &log.AffectedResources, &log.Metadata, &log.IntegrityHash}
+		if extra != nil {
+			dest = append(extra(), dest...)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		log.Timestamp = time.Unix(unixTimestamp, int64(timestampNanos)).UTC()
+		if log.Metadata == nil {
+			log.Metadata = map[string]any{}
+		}
+
+		logs = append(logs, log)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+func (p *PostgresPersistence) ListLogs(ctx context.Context, cursorTimestamp int64, cursorID string) ([]*core.Log, error) {
+	return p.SearchLogs(ctx, persistence.SearchQuery{
+		CursorTimestamp: cursorTimestamp,
+		CursorID:        cursorID,
+	})
+}
+
+// resourceCondition matches the rows of log_resources with a resource, or
+// under a prefix as a range, which the "C" collation of the column orders by
+// bytes.
+func resourceCondition(resource string) (string, []any) {
+	prefix, ok := persistence.ResourcePrefix(resource)
+	if !ok {
+		return "resource = ?", []any{resource}
+	}
+
+	end := persistence.PrefixEnd(prefix)
+	if end == "" {
+		return "resource >= ?", []any{prefix}
+	}
+
+	return "(resource >= ? AND resource < ?)", []any{prefix, end}
+}
+
+func (p *PostgresPersistence) SearchLogs(ctx context.Context, query persistence.SearchQuery) ([]*core.Log, error) {
+	var whereClauses []string
+	var args []any
+
+	// where adds a clause, numbering its ? placeholders after the
+	// arguments of the clauses before it.
+	where := func(clause string, clauseArgs ...any) {
+		for _, arg := range clauseArgs {
+			args = append(args, arg)
+			clause = strings.Replace(clause, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		whereClauses = append(whereClauses, clause)
+	}
+
+	if query.ServiceName != "" {
+		where("service_name = ?", query.ServiceName)
+	}
+
+	if query.Operation != "" {
+		where("operation = ?", query.Operation)
+	}
+
+	if query.ActorID != "" {
+		where("actor_id = ?", query.ActorID)
+	}
+
+	if query.ActorType != "" {
+		where("actor_type = ?", query.ActorType)
+	}
+
+	if len(query.AffectedResources) > 0 {
+		conditions := []string{}
+		conditionArgs := []any{}
+		for _, resource := range query.AffectedResources {
+			condition, resourceArgs := resourceCondition(resource)
+
+			if query.ResourceMatch == persistence.ResourceMatchAll {
+				where("id IN (SELECT log_id FROM log_resources WHERE "+condition+")", resourceArgs...)
+				continue
+			}
+
+			conditions = append(conditions, condition)
+			conditionArgs = append(conditionArgs, resourceArgs...)
+		}
+
+		if len(conditions) > 0 {
+			where("id IN (SELECT log_id FROM log_resources WHERE "+strings.Join(conditions, " OR ")+")", conditionArgs...)
+		}
+	}
+
+	if len(query.Metadata) > 0 {
+		where("metadata @> ?", query.Metadata)
+	}
+
+	if query.CursorTimestamp > 0 && query.CursorID != "" {
+		where("(timestamp < ? OR (timestamp = ? AND id < ?))", query.CursorTimestamp, query.CursorTimestamp, query.CursorID)
+	}
+
+	queryString := "SELECT " + logColumns + " FROM logs"
+	if len(whereClauses) > 0 {
+		queryString += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	queryString += " ORDER BY timestamp DESC, id DESC LIMIT 50"
+
+	rows, err := p.pool.Query(ctx, queryString, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanLogs(rows, nil)
+}
+
+func (p *PostgresPersistence) ListChainServices(ctx context.Context) ([]string, error) {
+	rows, err := p.pool.Query(ctx, "SELECT service_name FROM chain_heads UNION SELECT service_name FROM logs ORDER BY service_name")
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (p *PostgresPersistence) ListChain(ctx context.Context, serviceName string, afterPosition int64, limit int) ([]*persistence.ChainRecord, error) {
+	rows, err := p.pool.Query(ctx,
+		"SELECT position, chain_sequence, previous_hash, "+logColumns+" FROM logs WHERE service_name = $1 AND position > $2 ORDER BY position LIMIT $3",
+		serviceName, afterPosition, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*persistence.ChainRecord
+	logs, err := scanLogs(rows, func() []any {
+		record := &persistence.ChainRecord{}
+		records = append(records, record)
+		return []any{&record.Position, &record.Sequence, &record.PreviousHash}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, log := range logs {
+		records[i].Log = log
+	}
+
+	return records, nil
+}
+
+func (p *PostgresPersistence) GetChainCheckpoint(ctx context.Context, serviceName string) (*persistence.ChainCheckpoint, error) {
+	checkpoint := &persistence.ChainCheckpoint{}
+
+	err := p.pool.QueryRow(ctx,
+		"SELECT sequence, integrity_hash FROM chain_checkpoints WHERE service_name = $1",
+		serviceName,
+	).Scan(&checkpoint.Sequence, &checkpoint.IntegrityHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	return checkpoint, nil
+}
+
+func (p *PostgresPersistence) GetChainHead(ctx context.Context, serviceName string) (*persistence.ChainCheckpoint, error) {
+	head := &persistence.ChainCheckpoint{}
+
+	err := p.pool.QueryRow(ctx,
+		"SELECT sequence, integrity_hash FROM chain_heads WHERE service_name = $1",
+		serviceName,
+	).Scan(&head.Sequence, &head.IntegrityHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	return head, nil
+}
+
+// lockChains serializes writes to the hash chains of the services until
+// the transaction ends, across every collector sharing the database. Locks
+// are taken in order so that concurrent batches cannot deadlock.
+func lockChains(ctx context.Context, tx pgx.Tx, services []string) error {
+	services = slices.Clone(services)
+	slices.Sort(services)
+
+	for _, service := range slices.Compact(services) {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", service); err != nil {
+			return fmt.Errorf("failed to lock chain of %s: %w", service, err)
+		}
+	}
+
+	return nil
+}
+
+// chainHead returns the sequence and integrity hash recorded for the last
+// log of a service's chain. The chain continues from it even when logs were
+// deleted from its end, so that they show as missing.
+func chainHead(ctx context.Context, tx pgx.Tx, serviceName string) (*persistence.ChainCheckpoint, error) {
+	head := &persistence.ChainCheckpoint{}
+
+	err := tx.QueryRow(ctx,
+		"SELECT sequence, integrity_hash FROM chain_heads WHERE service_name = $1",
+		serviceName,
+	).Scan(&head.Sequence, &head.IntegrityHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read chain head: %w", err)
+	}
+
+	return head, nil
+}
+
+// recordChainHead records head as the last log of a service's chain.
+func recordChainHead(ctx context.Context, tx pgx.Tx, serviceName string, head *persistence.ChainCheckpoint) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO chain_heads (service_name, sequence, integrity_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (service_name) DO UPDATE SET sequence = excluded.sequence, integrity_hash = excluded.integrity_hash`,
+		serviceName, head.Sequence, head.IntegrityHash)
+	if err != nil {
+		return fmt.Errorf("failed to record chain head of %s: %w", serviceName, err)
+	}
+
+	return nil
+}
+
+// chainLog links the log to the head of its chain and advances the head,
+// returning the row to insert and the log's integrity hash.
+func chainLog(head *persistence.ChainCheckpoint, log *core.Log) ([]any, string, error) {
+	integrityHash, err := core.ChainHash(head.IntegrityHash, log)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to compute integrity hash: %w", err)
+	}
+
+	affectedResources := log.AffectedResources
+	if affectedResources == nil {
+		affectedResources = []string{}
+	}
+
+	metadata := log.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	row := []any{
+		log.ID,
+		log.Timestamp.Unix(),
+		int32(log.Timestamp.Nanosecond()),
+		log.ServiceName,
+		log.Operation,
+		log.ActorId,
+		log.ActorType,
+		affectedResources,
+		metadata,
+		integrityHash,
+		head.Sequence + 1,
+		head.IntegrityHash,
+	}
+
+	head.Sequence++
+	head.IntegrityHash = integrityHash
+
+	return row, integrityHash, nil
+}
+
+func (p *PostgresPersistence) PersistLog(ctx context.Context, log *core.Log) (*persistence.LogPersistenceResult, error) {
+	if err := log.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = lockChains(ctx, tx, []string{log.ServiceName}); err != nil {
+		return nil, err
+	}
+
+	head, err := chainHead(ctx, tx, log.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	row, integrityHash, err := chainLog(head, log)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholders := make([]string, len(insertColumns))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	tag, err := tx.Exec(ctx,
+		"INSERT INTO logs ("+strings.Join(insertColumns, ", ")+") VALUES ("+strings.Join(placeholders, ", ")+") ON CONFLICT (id) DO NOTHING",
+		row...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute statement: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil, core.ErrorAlreadyPersistedLog
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO log_resources (log_id, resource) SELECT $1, unnest($2::TEXT[]) ON CONFLICT DO NOTHING",
+		log.ID, log.AffectedResources,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert resources: %w", err)
+	}
+
+	if err = recordChainHead(ctx, tx, log.ServiceName, head); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.IntegrityHash = integrityHash
+
+	return &persistence.LogPersistenceResult{
+		ID:      log.ID.String(),
+		Success: true,
+	}, nil
+}
+
+// logResourceRows returns the rows of log_resources for the log, one per
+// distinct resource.
+func logResourceRows(log *core.Log) [][]any {
+	rows := [][]any{}
+	seen := map[string]bool{}
+
+	for _, resource := range log.AffectedResources {
+		if seen[resource] {
+			continue
+		}
+		seen[resource] = true
+
+		rows = append(rows, []any{log.ID, resource})
+	}
+
+	return rows
+}
+
+// storageFailure reports the logs that were to be persisted as failed to
+// store, so that the agent keeps them buffered and retries.
+func storageFailure(results []*persistence.LogPersistenceResult, err error) []*persistence.LogPersistenceResult {
+	reason := core.ErrorWithMessage(core.ErrorCodeStorageFailure, err.Error())
+	for _, result := range results {
+		if result.Success {
+			result.Success = false
+			result.Reason = reason
+		}
+	}
+
+	return results
+}
+
+// BatchPersistLog appends the logs to their chains with a single COPY.
+// Logs already persisted, or repeated in the batch, and invalid logs are
+// left out of it and reported as such. When the transaction fails, every
+// log that was to be persisted is reported as a storage failure.
+func (p *PostgresPersistence) BatchPersistLog(ctx context.Context, logs []*core.Log, allOrNothing bool) ([]*persistence.LogPersistenceResult, error) {
+	results := make([]*persistence.LogPersistenceResult, len(logs))
+	for i, log := range logs {
+		results[i] = &persistence.LogPersistenceResult{ID: log.ID.String(), Success: true}
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return storageFailure(results, fmt.Errorf("failed to begin transaction: %w", err)), nil
+	}
+	defer tx.Rollback(ctx)
+
+	services := make([]string, len(logs))
+	ids := make([]uuid.UUID, len(logs))
+	for i, log := range logs {
+		services[i] = log.ServiceName
+		ids[i] = log.ID
+	}
+
+	if err = lockChains(ctx, tx, services); err != nil {
+		return storageFailure(results, err), nil
+	}
+
+	rows, err := tx.Query(ctx, "SELECT id FROM logs WHERE id = ANY($1)", ids)
+	if err != nil {
+		return storageFailure(results, fmt.Errorf("failed to find persisted logs: %w", err)), nil
+	}
+
+	persisted, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return storageFailure(results, fmt.Errorf("failed to find persisted logs: %w", err)), nil
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for _, id := range persisted {
+		seen[id] = true
+	}
+
+	heads := map[string]*persistence.ChainCheckpoint{}
+	integrityHashes := make([]string, len(logs))
+	copyRows := [][]any{}
+	resourceRows := [][]any{}
+	invalid := false
+
+	for i, log := range logs {
+		if seen[log.ID] {
+			results[i].Success = false
+			results[i].Reason = core.ErrorAlreadyPersistedLog
+			continue
+		}
+
+		if err = log.Validate(); err != nil {
+			results[i].Success = false
+			results[i].Reason = err.(*core.Error)
+			invalid = true
+			continue
+		}
+
+		seen[log.ID] = true
+
+		head, ok := heads[log.ServiceName]
+		if !ok {
+			if head, err = chainHead(ctx, tx, log.ServiceName); err != nil {
+				return storageFailure(results, err), nil
+			}
+			heads[log.ServiceName] = head
+		}
+
+		row, integrityHash, err := chainLog(head, log)
+		if err != nil {
+			return storageFailure(results, err), nil
+		}
+
+		copyRows = append(copyRows, row)
+		resourceRows = append(resourceRows, logResourceRows(log)...)
+		integrityHashes[i] = integrityHash
+	}
+
+	if invalid && allOrNothing {
+		for _, result := range results {
+			if result.Success {
+				result.Success = false
+				result.Reason = core.ErrorBatchAborted
+			}
+		}
+		return results, nil
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"logs"}, insertColumns, pgx.CopyFromRows(copyRows))
+	if isUniqueViolation(err) {
+		// A log ID reused by another service is not covered by the locks
+		// held, and the conflict is only found by the insert. Logs are then
+		// persisted one by one to tell which ones conflict.
+		tx.Rollback(ctx)
+		return p.persistEach(ctx, logs, results), nil
+	}
+	if err != nil {
+		return storageFailure(results, fmt.Errorf("failed to copy logs: %w", err)), nil
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"log_resources"}, []string{"log_id", "resource"}, pgx.CopyFromRows(resourceRows))
+	if err != nil {
+		return storageFailure(results, fmt.Errorf("failed to copy resources: %w", err)), nil
+	}
+
+	for service, head := range heads {
+		if err = recordChainHead(ctx, tx, service, head); err != nil {
+			return storageFailure(results, err), nil
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return storageFailure(results, fmt.Errorf("failed to commit transaction: %w", err)), nil
+	}
+
+	for i, log := range logs {
+		if results[i].Success {
+			log.IntegrityHash = integrityHashes[i]
+		}
+	}
+
+	return results, nil
+}
+
+// persistEach persists, one by one, the logs whose results are still
+// successful.
+func (p *PostgresPersistence) persistEach(ctx context.Context, logs []*core.Log, results []*persistence.LogPersistenceResult) []*persistence.LogPersistenceResult {
+	for i, log := range logs {
+		if !results[i].Success {
+			continue
+		}
+
+		_, err := p.PersistLog(ctx, log)
+		if err == core.ErrorAlreadyPersistedLog || isUniqueViolation(err) {
+			results[i].Success = false
+			results[i].Reason = core.ErrorAlreadyPersistedLog
+		} else if err != nil {
+			results[i].Success = false
+			results[i].Reason = core.ErrorWithMessage(core.ErrorCodeStorageFailure, err.Error())
+		}
+	}
+
+	return results
+}
+
+func (p *PostgresPersistence) PurgeLogs(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, "SELECT DISTINCT service_name FROM logs")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	services, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	if err = lockChains(ctx, tx, services); err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, service := range services {
+		// The chain of a service is cut right before its first log that is
+		// not old enough, so that purging never leaves a gap in the middle
+		// of it.
+		var checkpoint persistence.ChainCheckpoint
+
+		err := tx.QueryRow(ctx, `
+			SELECT chain_sequence, integrity_hash FROM logs
+			WHERE service_name = $1 AND chain_sequence <= COALESCE(
+				(SELECT MIN(chain_sequence) - 1 FROM logs WHERE service_name = $1 AND timestamp >= $2),
+				(SELECT MAX(chain_sequence) FROM logs WHERE service_name = $1)
+			)
+			ORDER BY chain_sequence DESC LIMIT 1`,
+			service, before.Unix(),
+		).Scan(&checkpoint.Sequence, &checkpoint.IntegrityHash)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read purge boundary of %s: %w", service, err)
+		}
+
+		tag, err := tx.Exec(ctx, "DELETE FROM logs WHERE service_name = $1 AND chain_sequence <= $2", service, checkpoint.Sequence)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge logs of %s: %w", service, err)
+		}
+		purged += tag.RowsAffected()
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO chain_checkpoints (service_name, sequence, integrity_hash) VALUES ($1, $2, $3)
+			ON CONFLICT (service_name) DO UPDATE SET sequence = excluded.sequence, integrity_hash = excluded.integrity_hash`,
+			service, checkpoint.Sequence, checkpoint.IntegrityHash)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record checkpoint of %s: %w", service, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return purged, nil
+}
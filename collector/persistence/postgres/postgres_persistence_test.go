@@ -0,0 +1,328 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"oversee/collector/integrity"
+	"oversee/collector/persistence"
+	"oversee/core"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// testDSN returns a DSN for a schema of its own on the server named by
+// OVERSEE_TEST_POSTGRES_DSN, skipping the test when it is not set. The
+// schema is dropped once the test is done.
+func testDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv("OVERSEE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("OVERSEE_TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close(ctx) })
+
+	schema := fmt.Sprintf("oversee_test_%d", time.Now().UnixNano())
+	if _, err = conn.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := conn.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func newTestPersistence(t *testing.T) *PostgresPersistence {
+	t.Helper()
+
+	p, err := NewPostgresPersistence(testDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	return p
+}
+
+func newTestLog(serviceName string, operation string, resources ...string) *core.Log {
+	return &core.Log{
+		ID:                uuid.New(),
+		Timestamp:         time.Now().UTC(),
+		ServiceName:       serviceName,
+		Operation:         operation,
+		ActorId:           "actor",
+		ActorType:         "user",
+		AffectedResources: resources,
+		Metadata:          map[string]any{"amount": 12.5},
+	}
+}
+
+// verifyChains fails the test when a hash chain of p is broken.
+func verifyChains(t *testing.T, p *PostgresPersistence) {
+	t.Helper()
+
+	report, err := integrity.Verify(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, service := range report.Services {
+		for _, issue := range service.Issues {
+			t.Errorf("%s: %s", service.ServiceName, issue)
+		}
+	}
+}
+
+func TestPersistLog(t *testing.T) {
+	p := newTestPersistence(t)
+	ctx := context.Background()
+
+	log := newTestLog("billing", "invoice.pay", "invoice/1")
+	result, err := p.PersistLog(ctx, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Success || result.ID != log.ID.String() {
+		t.Errorf("PersistLog() = %+v, want success for %s", result, log.ID)
+	}
+
+	if _, err = p.PersistLog(ctx, log); err != core.ErrorAlreadyPersistedLog {
+		t.Errorf("persisting %s again returned %v, want %v", log.ID, err, core.ErrorAlreadyPersistedLog)
+	}
+
+	logs, err := p.ListLogs(ctx, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 1 || logs[0].ID != log.ID || !logs[0].Timestamp.Equal(log.Timestamp) || logs[0].IntegrityHash != log.IntegrityHash {
+		t.Errorf("ListLogs() = %+v, want the persisted log %+v", logs, log)
+	}
+
+	verifyChains(t, p)
+}
+
+func TestBatchPersistLog(t *testing.T) {
+	p := newTestPersistence(t)
+	ctx := context.Background()
+
+	persisted := newTestLog("billing", "invoice.create")
+	if _, err := p.PersistLog(ctx, persisted); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := newTestLog("billing", "invoice.pay")
+	invalid.Metadata = map[string]any{"amount": math.NaN()}
+
+	logs := []*core.Log{
+		newTestLog("billing", "invoice.pay"),
+		persisted,
+		invalid,
+		newTestLog("accounts", "user.create"),
+	}
+
+	results, err := p.BatchPersistLog(ctx, logs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCodes := []core.ErrorCode{core.ErrorCodeBatchAborted, core.ErrorCodeAlreadyPersistedLog, core.ErrorCodeInvalidLog, core.ErrorCodeBatchAborted}
+	for i, result := range results {
+		if result.Success || result.Reason.Code != wantCodes[i] {
+			t.Errorf("all-or-nothing result %d = %+v, want reason %d", i, result, wantCodes[i])
+		}
+	}
+
+	results, err = p.BatchPersistLog(ctx, logs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, result := range results {
+		switch i {
+		case 0, 3:
+			if !result.Success {
+				t.Errorf("result %d = %+v, want success", i, result)
+			}
+		default:
+			if result.Success || result.Reason.Code != wantCodes[i] {
+				t.Errorf("result %d = %+v, want reason %d", i, result, wantCodes[i])
+			}
+		}
+	}
+
+	stored, err := p.ListLogs(ctx, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 3 {
+		t.Errorf("%d logs are stored, want 3", len(stored))
+	}
+
+	verifyChains(t, p)
+}
+
+func TestSearchLogs(t *testing.T) {
+	p := newTestPersistence(t)
+	ctx := context.Background()
+
+	logs := []*core.Log{
+		newTestLog("billing", "invoice.pay", "org/1", "org/1/invoice/1"),
+		newTestLog("billing", "invoice.pay", "org/2/invoice/2"),
+		newTestLog("billing", "invoice.void", "org/1"),
+		newTestLog("accounts", "user.create", "org/1/user/1"),
+	}
+
+	for i, log := range logs {
+		log.Timestamp = log.Timestamp.Add(time.Duration(i) * time.Second)
+		if _, err := p.PersistLog(ctx, log); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query persistence.SearchQuery
+		want  []*core.Log
+	}{
+		{"service", persistence.SearchQuery{ServiceName: "billing"}, []*core.Log{logs[2], logs[1], logs[0]}},
+		{"operation", persistence.SearchQuery{ServiceName: "billing", Operation: "invoice.pay"}, []*core.Log{logs[1], logs[0]}},
+		{"resource", persistence.SearchQuery{AffectedResources: []string{"org/1"}}, []*core.Log{logs[2], logs[0]}},
+		{"resource prefix", persistence.SearchQuery{AffectedResources: []string{"org/1/*"}}, []*core.Log{logs[3], logs[0]}},
+		{"any of resources", persistence.SearchQuery{AffectedResources: []string{"org/2/*", "org/1/user/1"}}, []*core.Log{logs[3], logs[1]}},
+		{"all of resources", persistence.SearchQuery{AffectedResources: []string{"org/1", "org/1/invoice/*"}, ResourceMatch: persistence.ResourceMatchAll}, []*core.Log{logs[0]}},
+		{"after cursor", persistence.SearchQuery{ServiceName: "billing", CursorTimestamp: logs[1].Timestamp.Unix(), CursorID: logs[1].ID.String()}, []*core.Log{logs[0]}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := p.SearchLogs(ctx, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []uuid.UUID{}
+			for _, log := range found {
+				got = append(got, log.ID)
+			}
+
+			want := []uuid.UUID{}
+			for _, log := range test.want {
+				want = append(want, log.ID)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("SearchLogs() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMigrateChainsExistingLogs(t *testing.T) {
+	dsn := testDSN(t)
+	ctx := context.Background()
+
+	p, err := OpenPostgresPersistence(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// A database created before migrations existed only has the logs table.
+	if _, err = p.pool.Exec(ctx, migrations[0].SQL); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		_, err = p.pool.Exec(ctx,
+			"INSERT INTO logs (id, timestamp, service_name, operation, actor_id, actor_type, affected_resources, metadata, integrity_hash) VALUES ($1, $2, 'billing', 'invoice.pay', 'actor', 'user', $3, '{}', 'agent hash')",
+			uuid.New(), time.Now().Unix()+int64(i), []string{fmt.Sprintf("invoice/%d", i)},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	applied, err := p.Migrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != len(migrations) {
+		t.Errorf("Migrate() applied %d migrations, want %d", len(applied), len(migrations))
+	}
+
+	version, err := p.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := migrations[len(migrations)-1].Version; version != want {
+		t.Errorf("SchemaVersion() = %d, want %d", version, want)
+	}
+
+	if applied, err = p.Migrate(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("migrating again applied %d migrations, err %v, want none", len(applied), err)
+	}
+
+	if _, err = p.PersistLog(ctx, newTestLog("billing", "invoice.void")); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := p.ListChain(ctx, "billing", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, record := range records {
+		if record.Sequence != int64(i+1) {
+			t.Errorf("record %d has sequence %d, want %d", i, record.Sequence, i+1)
+		}
+	}
+
+	if len(records) != 4 {
+		t.Errorf("the chain has %d records, want 4", len(records))
+	}
+
+	found, err := p.SearchLogs(ctx, persistence.SearchQuery{AffectedResources: []string{"invoice/1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 1 {
+		t.Errorf("found %d logs by the resource of an existing log, want 1", len(found))
+	}
+
+	verifyChains(t, p)
+}
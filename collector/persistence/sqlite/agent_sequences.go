@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"oversee/collector/persistence"
+	"time"
+)
+
+func (s *SQLitePersistence) RecordAgentSequences(ctx context.Context, agentID string, sequences []int64) (*persistence.AgentSequence, []persistence.SequenceGap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	state := &persistence.AgentSequence{AgentID: agentID, UpdatedAt: time.Now().UTC()}
+
+	err = tx.QueryRowContext(ctx, "SELECT contiguous, highest FROM agent_sequences WHERE agent_id = ?", agentID).
+		Scan(&state.Contiguous, &state.Highest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("failed to read agent sequence: %w", err)
+	}
+
+	previousHighest := state.Highest
+
+	for _, sequence := range sequences {
+		if sequence <= state.Contiguous {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO agent_sequences_received (agent_id, sequence) VALUES (?, ?)", agentID, sequence)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to record agent sequence: %w", err)
+		}
+
+		state.Highest = max(state.Highest, sequence)
+	}
+
+	received, err := receivedSequences(ctx, tx, agentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for len(received) > 0 && received[0] == state.Contiguous+1 {
+		state.Contiguous++
+		received = received[1:]
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM agent_sequences_received WHERE agent_id = ? AND sequence <= ?", agentID, state.Contiguous)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to trim agent sequences: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO agent_sequences (agent_id, contiguous, highest, updated_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (agent_id) DO UPDATE SET contiguous = excluded.contiguous, highest = excluded.highest, updated_at = excluded.updated_at`,
+		agentID, state.Contiguous, state.Highest, state.UpdatedAt.UnixNano())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update agent sequence: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	state.Gaps = persistence.FindSequenceGaps(state.Contiguous, received)
+
+	return state, persistence.OverdueSequenceGaps(state.Gaps, previousHighest, state.Highest), nil
+}
+
+func (s *SQLitePersistence) ListAgentSequences(ctx context.Context) ([]*persistence.AgentSequence, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT agent_id, contiguous, highest, updated_at FROM agent_sequences ORDER BY agent_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agent sequences: %w", err)
+	}
+	defer rows.Close()
+
+	states := []*persistence.AgentSequence{}
+	for rows.Next() {
+		state := &persistence.AgentSequence{}
+		var updatedAt int64
+
+		if err = rows.Scan(&state.AgentID, &state.Contiguous, &state.Highest, &updatedAt); err != nil {
+			return nil, err
+		}
+
+		state.UpdatedAt = time.Unix(0, updatedAt).UTC()
+		states = append(states, state)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, state := range states {
+		received, err := receivedSequences(ctx, s.db, state.AgentID)
+		if err != nil {
+			return nil, err
+		}
+
+		state.Gaps = persistence.FindSequenceGaps(state.Contiguous, received)
+	}
+
+	return states, nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// receivedSequences returns the sequence numbers received from an agent
+// past its contiguous one, in order.
+func receivedSequences(ctx context.Context, q querier, agentID string) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, "SELECT sequence FROM agent_sequences_received WHERE agent_id = ? ORDER BY sequence", agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent sequences: %w", err)
+	}
+	defer rows.Close()
+
+	received := []int64{}
+	for rows.Next() {
+		var sequence int64
+		if err = rows.Scan(&sequence); err != nil {
+			return nil, err
+		}
+		received = append(received, sequence)
+	}
+
+	return received, rows.Err()
+}
@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"oversee/collector/persistence"
+	"time"
+)
+
+func (s *SQLitePersistence) RegisterAgent(ctx context.Context, registration *persistence.AgentRegistration) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstSeen int64
+
+	err := s.db.QueryRowContext(ctx, `
+INSERT INTO agents (agent_id, application, application_version, agent_version, hostname, os, arch, pid, started_at, buffered_records, buffered_bytes, spilled_records, stopped, first_seen, last_seen)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (agent_id) DO UPDATE SET
+	application = excluded.application,
+	application_version = excluded.application_version,
+	agent_version = excluded.agent_version,
+	hostname = excluded.hostname,
+	os = excluded.os,
+	arch = excluded.arch,
+	pid = excluded.pid,
+	started_at = excluded.started_at,
+	buffered_records = excluded.buffered_records,
+	buffered_bytes = excluded.buffered_bytes,
+	spilled_records = excluded.spilled_records,
+	stopped = excluded.stopped,
+	last_seen = excluded.last_seen
+RETURNING first_seen`,
+		registration.AgentID,
+		registration.Application,
+		registration.ApplicationVersion,
+		registration.AgentVersion,
+		registration.Hostname,
+		registration.OS,
+		registration.Arch,
+		registration.PID,
+		registration.StartedAt.UnixNano(),
+		registration.BufferedRecords,
+		registration.BufferedBytes,
+		registration.SpilledRecords,
+		registration.Stopped,
+		registration.LastSeen.UnixNano(),
+		registration.LastSeen.UnixNano(),
+	).Scan(&firstSeen)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	return time.Unix(0, firstSeen).UTC(), nil
+}
+
+func (s *SQLitePersistence) ListAgents(ctx context.Context) ([]*persistence.AgentRegistration, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT agent_id, application, application_version, agent_version, hostname, os, arch, pid, started_at, buffered_records, buffered_bytes, spilled_records, stopped, first_seen, last_seen
+FROM agents ORDER BY agent_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	defer rows.Close()
+
+	agents := []*persistence.AgentRegistration{}
+	for rows.Next() {
+		agent := &persistence.AgentRegistration{}
+		var startedAt, firstSeen, lastSeen int64
+
+		err = rows.Scan(
+			&agent.AgentID,
+			&agent.Application,
+			&agent.ApplicationVersion,
+			&agent.AgentVersion,
+			&agent.Hostname,
+			&agent.OS,
+			&agent.Arch,
+			&agent.PID,
+			&startedAt,
+			&agent.BufferedRecords,
+			&agent.BufferedBytes,
+			&agent.SpilledRecords,
+			&agent.Stopped,
+			&firstSeen,
+			&lastSeen,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		agent.StartedAt = time.Unix(0, startedAt).UTC()
+		agent.FirstSeen = time.Unix(0, firstSeen).UTC()
+		agent.LastSeen = time.Unix(0, lastSeen).UTC()
+		agents = append(agents, agent)
+	}
+
+	return agents, rows.Err()
+}
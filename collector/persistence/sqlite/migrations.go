@@ -0,0 +1,374 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"oversee/collector/persistence"
+	"oversee/core"
+	"time"
+)
+
+// migrations are the steps of the schema, in order. Applied migrations must
+// never change, new ones go at the end. The first one creates the logs table
+// as it was before migrations existed, only if missing, so that older
+// databases are brought up to date by the ones after it.
+var migrations = []persistence.Migration{
+	{
+		Version:     1,
+		Description: "create logs",
+		SQL: `
+CREATE TABLE IF NOT EXISTS logs (
+	id CHAR(36) PRIMARY KEY,
+	timestamp INTEGER NOT NULL,
+	service_name TEXT NOT NULL,
+	operation TEXT NOT NULL,
+	actor_id TEXT NOT NULL,
+	actor_type TEXT NOT NULL,
+	affected_resources TEXT NOT NULL,
+	metadata TEXT NOT NULL,
+	integrity_hash TEXT NOT NULL
+);
+`,
+	},
+	{
+		Version:     2,
+		Description: "add hash chain columns and chain existing logs",
+		// Existing logs only have second precision timestamps. They are
+		// chained by chainExistingLogs once the columns exist.
+		SQL: `
+ALTER TABLE logs ADD COLUMN timestamp_nanos INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE logs ADD COLUMN chain_sequence INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE logs ADD COLUMN previous_hash TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		Version:     3,
+		Description: "index hash chains and create chain checkpoints",
+		SQL: `
+CREATE UNIQUE INDEX IF NOT EXISTS logs_chain ON logs (service_name, chain_sequence);
+CREATE TABLE IF NOT EXISTS chain_checkpoints (
+	service_name TEXT PRIMARY KEY,
+	sequence INTEGER NOT NULL,
+	integrity_hash TEXT NOT NULL
+);
+`,
+	},
+	{
+		Version:     4,
+		Description: "create agent sequence tables",
+		// Sequence numbers up to the contiguous one are only counted, those
+		// received past it are kept until the ones before them arrive.
+		SQL: `
+CREATE TABLE IF NOT EXISTS agent_sequences (
+	agent_id TEXT PRIMARY KEY,
+	contiguous INTEGER NOT NULL,
+	highest INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS agent_sequences_received (
+	agent_id TEXT NOT NULL,
+	sequence INTEGER NOT NULL,
+	PRIMARY KEY (agent_id, sequence)
+);
+`,
+	},
+	{
+		Version:     5,
+		Description: "create agent registry",
+		SQL: `
+CREATE TABLE IF NOT EXISTS agents (
+	agent_id TEXT PRIMARY KEY,
+	application TEXT NOT NULL,
+	application_version TEXT NOT NULL,
+	agent_version TEXT NOT NULL,
+	hostname TEXT NOT NULL,
+	os TEXT NOT NULL,
+	arch TEXT NOT NULL,
+	pid INTEGER NOT NULL,
+	started_at INTEGER NOT NULL,
+	buffered_records INTEGER NOT NULL,
+	buffered_bytes INTEGER NOT NULL,
+	spilled_records INTEGER NOT NULL,
+	stopped INTEGER NOT NULL,
+	first_seen INTEGER NOT NULL,
+	last_seen INTEGER NOT NULL
+);
+`,
+	},
+	{
+		Version:     6,
+		Description: "index logs for search",
+		// Searches filter on one of the columns and read the newest logs
+		// first, so each index continues with the order of the results.
+		SQL: `
+CREATE INDEX IF NOT EXISTS logs_timestamp ON logs (timestamp, id);
+CREATE INDEX IF NOT EXISTS logs_service_timestamp ON logs (service_name, timestamp, id);
+CREATE INDEX IF NOT EXISTS logs_operation_timestamp ON logs (operation, timestamp, id);
+CREATE INDEX IF NOT EXISTS logs_actor_id_timestamp ON logs (actor_id, timestamp, id);
+CREATE INDEX IF NOT EXISTS logs_actor_type_timestamp ON logs (actor_type, timestamp, id);
+`,
+	},
+	{
+		Version:     7,
+		Description: "create log resources",
+		// The resources of the logs already stored are copied over. Those
+		// were stored as JSON blobs, which json_each only reads as text.
+		SQL: `
+CREATE TABLE IF NOT EXISTS log_resources (
+	log_id CHAR(36) NOT NULL,
+	resource TEXT NOT NULL,
+	PRIMARY KEY (resource, log_id)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS log_resources_log ON log_resources (log_id);
+INSERT OR IGNORE INTO log_resources (log_id, resource)
+	SELECT logs.id, resources.value
+	FROM logs, json_each(CAST(logs.affected_resources AS TEXT)) AS resources
+	WHERE json_type(CAST(logs.affected_resources AS TEXT)) = 'array' AND resources.type = 'text';
+`,
+	},
+	{
+		Version:     8,
+		Description: "create chain heads",
+		// The head of a chain is its last log, or its checkpoint when every
+		// log was purged.
+		SQL: `
+CREATE TABLE IF NOT EXISTS chain_heads (
+	service_name TEXT PRIMARY KEY,
+	sequence INTEGER NOT NULL,
+	integrity_hash TEXT NOT NULL
+);
+INSERT OR IGNORE INTO chain_heads (service_name, sequence, integrity_hash)
+	SELECT service_name, chain_sequence, integrity_hash FROM logs heads
+	WHERE chain_sequence = (SELECT MAX(chain_sequence) FROM logs WHERE service_name = heads.service_name);
+INSERT OR IGNORE INTO chain_heads (service_name, sequence, integrity_hash)
+	SELECT service_name, sequence, integrity_hash FROM chain_checkpoints;
+`,
+	},
+}
+
+// ChainSchemaVersion is the first schema version whose logs are all in their
+// service's hash chain.
+const ChainSchemaVersion = 3
+
+// ChainHeadSchemaVersion is the first schema version recording the head of
+// every chain, without which logs deleted from the end of a chain go
+// unnoticed.
+const ChainHeadSchemaVersion = 8
+
+// migrationSteps are run after the SQL of the migration with the same
+// version, in its transaction, for what SQL alone cannot do.
+var migrationSteps = map[int]func(ctx context.Context, tx *sql.Tx) error{
+	2: chainExistingLogs,
+}
+
+const createSchemaVersionTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)
+`
+
+func (s *SQLitePersistence) SchemaVersion(ctx context.Context) (int, error) {
+	var tables int
+
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'").Scan(&tables)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if tables == 0 {
+		return 0, nil
+	}
+
+	var version int
+	if err = s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+func (s *SQLitePersistence) PendingMigrations(ctx context.Context) ([]persistence.Migration, error) {
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return persistence.PendingMigrations(migrations, version)
+}
+
+// Migrate applies each pending migration in its own transaction. A
+// migration applied concurrently by another collector makes the recording
+// of its version fail, and the transaction with it.
+func (s *SQLitePersistence) Migrate(ctx context.Context) ([]persistence.Migration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, createSchemaVersionTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create schema version table: %w", err)
+	}
+
+	pending, err := s.PendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []persistence.Migration{}
+	for _, migration := range pending {
+		if err = s.applyMigration(ctx, migration); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+func (s *SQLitePersistence) applyMigration(ctx context.Context, migration persistence.Migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, migration.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+	}
+
+	if step, ok := migrationSteps[migration.Version]; ok {
+		if err = step(ctx, tx); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Description, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	}
+
+	return nil
+}
+
+// chainExistingLogs links the logs stored before the hash chain existed,
+// which have no chain sequence, into the chain of their service in the order
+// they were stored. Their integrity hash, which agents used to fill in, is
+// replaced by their hash in the chain.
+func chainExistingLogs(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT service_name FROM logs WHERE chain_sequence = 0")
+	if err != nil {
+		return fmt.Errorf("failed to find unchained logs: %w", err)
+	}
+
+	var services []string
+	for rows.Next() {
+		var service string
+		if err = rows.Scan(&service); err != nil {
+			rows.Close()
+			return err
+		}
+		services = append(services, service)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		if err = chainExistingServiceLogs(ctx, tx, service); err != nil {
+			return fmt.Errorf("failed to chain logs of %s: %w", service, err)
+		}
+	}
+
+	return nil
+}
+
+func chainExistingServiceLogs(ctx context.Context, tx *sql.Tx, serviceName string) error {
+	// Logs chained already, if any, come before the ones being chained.
+	head := &persistence.ChainCheckpoint{}
+	err := tx.QueryRowContext(ctx,
+		"SELECT chain_sequence, integrity_hash FROM logs WHERE service_name = ? AND chain_sequence > 0 ORDER BY chain_sequence DESC LIMIT 1",
+		serviceName,
+	).Scan(&head.Sequence, &head.IntegrityHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read chain head: %w", err)
+	}
+
+	for {
+		rows, err := tx.QueryContext(ctx,
+			`SELECT rowid, id, timestamp, timestamp_nanos, service_name, operation, actor_id, actor_type, affected_resources, metadata
+			FROM logs WHERE service_name = ? AND chain_sequence = 0 ORDER BY rowid LIMIT ?`,
+			serviceName, batchInsertSize,
+		)
+		if err != nil {
+			return err
+		}
+
+		var rowids []int64
+		var logs []*core.Log
+		for rows.Next() {
+			log := &core.Log{}
+
+			var rowid, unixTimestamp, timestampNanos int64
+			var affectedResources []byte
+			var metadataJSON string
+
+			if err = rows.Scan(&rowid, &log.ID, &unixTimestamp, &timestampNanos, &log.ServiceName, &log.Operation, &log.ActorId, &log.ActorType, &affectedResources, &metadataJSON); err != nil {
+				rows.Close()
+				return err
+			}
+
+			log.Timestamp = time.Unix(unixTimestamp, timestampNanos).UTC()
+
+			log.Metadata = map[string]any{}
+			if err = json.Unmarshal([]byte(metadataJSON), &log.Metadata); err != nil {
+				rows.Close()
+				return err
+			}
+
+			if err = json.Unmarshal(affectedResources, &log.AffectedResources); err != nil {
+				rows.Close()
+				return err
+			}
+
+			rowids = append(rowids, rowid)
+			logs = append(logs, log)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for i, log := range logs {
+			integrityHash, err := core.ChainHash(head.IntegrityHash, log)
+			if err != nil {
+				return fmt.Errorf("failed to compute integrity hash of %s: %w", log.ID, err)
+			}
+
+			_, err = tx.ExecContext(ctx,
+				"UPDATE logs SET chain_sequence = ?, previous_hash = ?, integrity_hash = ? WHERE rowid = ?",
+				head.Sequence+1, head.IntegrityHash, integrityHash, rowids[i],
+			)
+			if err != nil {
+				return err
+			}
+
+			head.Sequence++
+			head.IntegrityHash = integrityHash
+		}
+
+		if len(logs) < batchInsertSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package sqlite
+
+import (
+	"context"
+	"oversee/collector/persistence"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// searchFilters are the columns searches filter on with an index.
+var searchFilters = []struct {
+	column string
+	set    func(*persistence.SearchQuery)
+}{
+	{"service_name", func(q *persistence.SearchQuery) { q.ServiceName = "service" }},
+	{"operation", func(q *persistence.SearchQuery) { q.Operation = "operation" }},
+	{"actor_id", func(q *persistence.SearchQuery) { q.ActorID = "actor" }},
+	{"actor_type", func(q *persistence.SearchQuery) { q.ActorType = "user" }},
+}
+
+// resourceFilters are the ways searches match affected resources.
+var resourceFilters = []struct {
+	name string
+	set  func(*persistence.SearchQuery)
+}{
+	{"resource", func(q *persistence.SearchQuery) {
+		q.AffectedResources = []string{"org/1"}
+	}},
+	{"any of resources and prefixes", func(q *persistence.SearchQuery) {
+		q.AffectedResources = []string{"org/1", "org/2/project/*"}
+	}},
+	{"all of resources and prefixes", func(q *persistence.SearchQuery) {
+		q.AffectedResources = []string{"org/1", "org/2/project/*"}
+		q.ResourceMatch = persistence.ResourceMatchAll
+	}},
+}
+
+// searchShapes returns every combination of the indexed filters, then every
+// way of matching resources, with and without a cursor, along with their
+// names.
+func searchShapes() ([]string, []persistence.SearchQuery) {
+	names := []string{}
+	queries := []persistence.SearchQuery{}
+
+	for set := 0; set < 1<<len(searchFilters); set++ {
+		query := persistence.SearchQuery{}
+		columns := []string{}
+
+		for i, filter := range searchFilters {
+			if set&(1<<i) != 0 {
+				filter.set(&query)
+				columns = append(columns, filter.column)
+			}
+		}
+
+		name := "no filter"
+		if len(columns) > 0 {
+			name = strings.Join(columns, ", ")
+		}
+
+		names = append(names, name)
+		queries = append(queries, query)
+	}
+
+	for _, filter := range resourceFilters {
+		query := persistence.SearchQuery{}
+		filter.set(&query)
+
+		names = append(names, filter.name)
+		queries = append(queries, query)
+	}
+
+	for i := range queries {
+		query := queries[i]
+		query.CursorTimestamp = 1
+		query.CursorID = "00000000-0000-0000-0000-000000000000"
+
+		names = append(names, names[i]+" after cursor")
+		queries = append(queries, query)
+	}
+
+	return names, queries
+}
+
+// explainSearch returns the details of the plan SQLite would use to run the
+// search, in order.
+func explainSearch(t *testing.T, s *SQLitePersistence, query persistence.SearchQuery) []string {
+	t.Helper()
+
+	queryString, args, err := searchLogsQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := s.db.QueryContext(context.Background(), "EXPLAIN QUERY PLAN "+queryString, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	steps := []string{}
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+
+		if err = rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatal(err)
+		}
+		steps = append(steps, detail)
+	}
+
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return steps
+}
+
+// usesIndex tells whether the logs are read through an index, in the order
+// of the results, rather than scanned and sorted. Logs found by their ID
+// through the resources they touch may be sorted, there are only as many as
+// match.
+func usesIndex(steps []string) bool {
+	byID := false
+	sorted := false
+
+	for _, step := range steps {
+		switch {
+		case strings.HasPrefix(step, "SCAN ") && !strings.Contains(step, "INDEX"):
+			return false
+		case strings.HasPrefix(step, "SEARCH logs") && strings.Contains(step, "(id=?)"):
+			byID = true
+		case strings.Contains(step, "TEMP B-TREE"):
+			sorted = true
+		}
+	}
+
+	return len(steps) > 0 && (!sorted || byID)
+}
+
+func newTestPersistence(t *testing.T) *SQLitePersistence {
+	t.Helper()
+
+	s, err := NewSQLitePersistence(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestSearchesUseIndexes(t *testing.T) {
+	s := newTestPersistence(t)
+	names, queries := searchShapes()
+
+	for i, query := range queries {
+		t.Run(names[i], func(t *testing.T) {
+			if steps := explainSearch(t, s, query); !usesIndex(steps) {
+				t.Errorf("search does not use an index:\n%s", strings.Join(steps, "\n"))
+			}
+		})
+	}
+}
+
+func TestSearchWithoutIndexScans(t *testing.T) {
+	s := newTestPersistence(t)
+
+	if _, err := s.db.Exec("DROP INDEX logs_service_timestamp"); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := explainSearch(t, s, persistence.SearchQuery{ServiceName: "service"})
+	if usesIndex(steps) {
+		t.Errorf("search without its index is reported as using one:\n%s", strings.Join(steps, "\n"))
+	}
+}
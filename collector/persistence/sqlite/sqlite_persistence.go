@@ -4,84 +4,257 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"oversee/collector/persistence"
 	"oversee/core"
 
+	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type SQLitePersistence struct {
 	db *sql.DB
+	// mu serializes writes so that each service's hash chain is extended by
+	// one log at a time.
+	mu sync.Mutex
 }
 
+// NewSQLitePersistence opens the database and applies the pending
+// migrations.
 func NewSQLitePersistence(dbPath string) (*SQLitePersistence, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	s, err := OpenSQLitePersistence(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	if err = createLogTable(db); err != nil {
-		return nil, fmt.Errorf("failed to create log table: %w", err)
+	if _, err = s.Migrate(context.Background()); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// OpenSQLitePersistence opens the database without touching its schema.
+func OpenSQLitePersistence(dbPath string) (*SQLitePersistence, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	return &SQLitePersistence{db: db}, nil
 }
 
+func (s *SQLitePersistence) Close() error {
+	return s.db.Close()
+}
+
 func isUniqueConstraintError(err error) bool {
 	return strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
 
 func (s *SQLitePersistence) ListLogs(ctx context.Context, cursorTimestamp int64, cursorID string) ([]*core.Log, error) {
-	queryString := "SELECT id, timestamp, service_name, operation, actor_id, actor_type, affected_resources, metadata, integrity_hash FROM logs"
-	if cursorTimestamp > 0 && cursorID != "" {
-		queryString += " WHERE (timestamp < ? OR (timestamp = ? AND id < ?))"
-	}
-	queryString += " ORDER BY timestamp DESC, id DESC LIMIT 50"
+	return s.SearchLogs(ctx, persistence.SearchQuery{
+		CursorTimestamp: cursorTimestamp,
+		CursorID:        cursorID,
+	})
+}
 
-	rows, err := s.db.QueryContext(ctx, queryString, cursorTimestamp, cursorTimestamp, cursorID)
+func (s *SQLitePersistence) ListChainServices(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT service_name FROM chain_heads UNION SELECT service_name FROM logs ORDER BY service_name")
 	if err != nil {
 		return nil, err
 	}
-
 	defer rows.Close()
 
-	var logs []*core.Log
+	var services []string
+	for rows.Next() {
+		var service string
+		if err := rows.Scan(&service); err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+func (s *SQLitePersistence) ListChain(ctx context.Context, serviceName string, afterPosition int64, limit int) ([]*persistence.ChainRecord, error) {
+	queryString := `SELECT rowid, chain_sequence, previous_hash, id, timestamp, timestamp_nanos, service_name, operation, actor_id, actor_type, affected_resources, metadata, integrity_hash
+		FROM logs WHERE service_name = ? AND rowid > ? ORDER BY rowid LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, queryString, serviceName, afterPosition, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	var records []*persistence.ChainRecord
 	for rows.Next() {
-		log := &core.Log{}
+		record := &persistence.ChainRecord{Log: &core.Log{}}
+		log := record.Log
+
 		var metadataJSON string
-		var unixTimestamp int64
+		var unixTimestamp, timestampNanos int64
 		var affectedResources []byte
 
-		if err = rows.Scan(&log.ID, &unixTimestamp, &log.ServiceName, &log.Operation, &log.ActorId, &log.ActorType, &affectedResources, &metadataJSON, &log.IntegrityHash); err != nil {
+		if err := rows.Scan(&record.Position, &record.Sequence, &record.PreviousHash, &log.ID, &unixTimestamp, &timestampNanos, &log.ServiceName, &log.Operation, &log.ActorId, &log.ActorType, &affectedResources, &metadataJSON, &log.IntegrityHash); err != nil {
 			return nil, err
 		}
 
 		log.Metadata = map[string]any{}
-		if err = json.Unmarshal([]byte(metadataJSON), &log.Metadata); err != nil {
+		if err := json.Unmarshal([]byte(metadataJSON), &log.Metadata); err != nil {
 			return nil, err
 		}
 
-		log.Timestamp = time.Unix(unixTimestamp, 0)
+		log.Timestamp = time.Unix(unixTimestamp, timestampNanos).UTC()
 
-		if err = json.Unmarshal(affectedResources, &log.AffectedResources); err != nil {
+		if err := json.Unmarshal(affectedResources, &log.AffectedResources); err != nil {
 			return nil, err
 		}
-		logs = append(logs, log)
+
+		records = append(records, record)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return logs, nil
+	return records, nil
 }
 
-func (s *SQLitePersistence) SearchLogs(ctx context.Context, query persistence.SearchQuery) ([]*core.Log, error) {
+func (s *SQLitePersistence) GetChainCheckpoint(ctx context.Context, serviceName string) (*persistence.ChainCheckpoint, error) {
+	checkpoint := &persistence.ChainCheckpoint{}
+
+	err := s.db.QueryRowContext(ctx,
+		"SELECT sequence, integrity_hash FROM chain_checkpoints WHERE service_name = ?",
+		serviceName,
+	).Scan(&checkpoint.Sequence, &checkpoint.IntegrityHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return checkpoint, nil
+}
+
+func (s *SQLitePersistence) GetChainHead(ctx context.Context, serviceName string) (*persistence.ChainCheckpoint, error) {
+	head := &persistence.ChainCheckpoint{}
+
+	err := s.db.QueryRowContext(ctx,
+		"SELECT sequence, integrity_hash FROM chain_heads WHERE service_name = ?",
+		serviceName,
+	).Scan(&head.Sequence, &head.IntegrityHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return head, nil
+}
+
+func (s *SQLitePersistence) PurgeLogs(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The chain of a service is cut right before its first log that is not
+	// old enough, so that purging never leaves a gap in the middle of it.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT s.service_name, COALESCE(
+			(SELECT MIN(k.chain_sequence) - 1 FROM logs k WHERE k.service_name = s.service_name AND k.timestamp >= ?),
+			MAX(s.chain_sequence)
+		)
+		FROM logs s GROUP BY s.service_name`, before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to find purge boundaries: %w", err)
+	}
+
+	boundaries := map[string]int64{}
+	for rows.Next() {
+		var service string
+		var boundary int64
+		if err := rows.Scan(&service, &boundary); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		boundaries[service] = boundary
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	checkpoints := map[string]persistence.ChainCheckpoint{}
+	for service, boundary := range boundaries {
+		var checkpoint persistence.ChainCheckpoint
+
+		err := tx.QueryRowContext(ctx,
+			"SELECT chain_sequence, integrity_hash FROM logs WHERE service_name = ? AND chain_sequence <= ? ORDER BY chain_sequence DESC LIMIT 1",
+			service, boundary,
+		).Scan(&checkpoint.Sequence, &checkpoint.IntegrityHash)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read purge boundary of %s: %w", service, err)
+		}
+
+		checkpoints[service] = checkpoint
+	}
+
+	var purged int64
+	for service, checkpoint := range checkpoints {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM log_resources WHERE log_id IN (SELECT id FROM logs WHERE service_name = ? AND chain_sequence <= ?)",
+			service, checkpoint.Sequence,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge resources of %s: %w", service, err)
+		}
+
+		res, err := tx.ExecContext(ctx, "DELETE FROM logs WHERE service_name = ? AND chain_sequence <= ?", service, checkpoint.Sequence)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge logs of %s: %w", service, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += n
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO chain_checkpoints (service_name, sequence, integrity_hash) VALUES (?, ?, ?)
+			ON CONFLICT (service_name) DO UPDATE SET sequence = excluded.sequence, integrity_hash = excluded.integrity_hash`,
+			service, checkpoint.Sequence, checkpoint.IntegrityHash)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record checkpoint of %s: %w", service, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return purged, nil
+}
+
+// searchLogsQuery builds the statement SearchLogs runs for query.
+func searchLogsQuery(query persistence.SearchQuery) (string, []any, error) {
 	var whereClauses []string
 	var args []any
 
@@ -106,33 +279,69 @@ func (s *SQLitePersistence) SearchLogs(ctx context.Context, query persistence.Se
 	}
 
 	if len(query.AffectedResources) > 0 {
-		resourcesJSON, err := json.Marshal(query.AffectedResources)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal affected resources: %w", err)
+		conditions := []string{}
+		for _, resource := range query.AffectedResources {
+			condition, resourceArgs := resourceCondition(resource)
+			conditions = append(conditions, condition)
+			args = append(args, resourceArgs...)
+		}
+
+		if query.ResourceMatch == persistence.ResourceMatchAll {
+			for _, condition := range conditions {
+				whereClauses = append(whereClauses, "id IN (SELECT log_id FROM log_resources WHERE "+condition+")")
+			}
+		} else {
+			whereClauses = append(whereClauses, "id IN (SELECT log_id FROM log_resources WHERE "+strings.Join(conditions, " OR ")+")")
 		}
-		whereClauses = append(whereClauses, "affected_resources = ?")
-		args = append(args, string(resourcesJSON))
 	}
 
 	if len(query.Metadata) > 0 {
 		metadataJSON, err := json.Marshal(query.Metadata)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+			return "", nil, fmt.Errorf("failed to marshal metadata: %w", err)
 		}
 		whereClauses = append(whereClauses, "metadata LIKE ?")
 		args = append(args, "%"+string(metadataJSON)+"%")
 	}
 
-	queryString := "SELECT id, timestamp, service_name, operation, actor_id, actor_type, affected_resources, metadata, integrity_hash FROM logs"
+	// The cursor compares a row value so that it bounds the range of the
+	// index read rather than filtering every row before it.
+	if query.CursorTimestamp > 0 && query.CursorID != "" {
+		whereClauses = append(whereClauses, "(timestamp, id) < (?, ?)")
+		args = append(args, query.CursorTimestamp, query.CursorID)
+	}
+
+	queryString := "SELECT id, timestamp, timestamp_nanos, service_name, operation, actor_id, actor_type, affected_resources, metadata, integrity_hash FROM logs"
 	if len(whereClauses) > 0 {
 		queryString += " WHERE " + strings.Join(whereClauses, " AND ")
 	}
-	if query.CursorTimestamp > 0 && query.CursorID != "" {
-		queryString += " AND (timestamp < ? OR (timestamp = ? AND id < ?))"
-		args = append(args, query.CursorTimestamp, query.CursorTimestamp, query.CursorID)
-	}
 	queryString += " ORDER BY timestamp DESC, id DESC LIMIT 50"
 
+	return queryString, args, nil
+}
+
+// resourceCondition matches the rows of log_resources with a resource, or
+// under a prefix as a range so that the index is used.
+func resourceCondition(resource string) (string, []any) {
+	prefix, ok := persistence.ResourcePrefix(resource)
+	if !ok {
+		return "resource = ?", []any{resource}
+	}
+
+	end := persistence.PrefixEnd(prefix)
+	if end == "" {
+		return "resource >= ?", []any{prefix}
+	}
+
+	return "(resource >= ? AND resource < ?)", []any{prefix, end}
+}
+
+func (s *SQLitePersistence) SearchLogs(ctx context.Context, query persistence.SearchQuery) ([]*core.Log, error) {
+	queryString, args, err := searchLogsQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := s.db.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, err
@@ -144,10 +353,10 @@ func (s *SQLitePersistence) SearchLogs(ctx context.Context, query persistence.Se
 		log := &core.Log{}
 
 		var metadataJSON string
-		var unixTimestamp int64
+		var unixTimestamp, timestampNanos int64
 		var affectedResources []byte
 
-		if err := rows.Scan(&log.ID, &unixTimestamp, &log.ServiceName, &log.Operation, &log.ActorId, &log.ActorType, &affectedResources, &metadataJSON, &log.IntegrityHash); err != nil {
+		if err := rows.Scan(&log.ID, &unixTimestamp, &timestampNanos, &log.ServiceName, &log.Operation, &log.ActorId, &log.ActorType, &affectedResources, &metadataJSON, &log.IntegrityHash); err != nil {
 			fmt.Println(err.Error())
 			return nil, err
 		}
@@ -157,7 +366,7 @@ func (s *SQLitePersistence) SearchLogs(ctx context.Context, query persistence.Se
 			return nil, err
 		}
 
-		log.Timestamp = time.Unix(unixTimestamp, 0)
+		log.Timestamp = time.Unix(unixTimestamp, timestampNanos).UTC()
 
 		log.AffectedResources = []string{}
 		if err := json.Unmarshal(affectedResources, &log.AffectedResources); err != nil {
@@ -174,49 +383,199 @@ func (s *SQLitePersistence) SearchLogs(ctx context.Context, query persistence.Se
 	return logs, nil
 }
 
-func (s *SQLitePersistence) PersistLog(ctx context.Context, log *core.Log) (*persistence.LogPersistenceResult, error) {
-	query := `
-		INSERT INTO logs (
-			id,
-			timestamp,
-			service_name,
-			operation,
-			actor_id,
-			actor_type,
-			affected_resources,
-			metadata,
-			integrity_hash
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-	stmt, err := s.db.PrepareContext(ctx, query)
+const insertLogQuery = `
+	INSERT INTO logs (
+		id,
+		timestamp,
+		timestamp_nanos,
+		service_name,
+		operation,
+		actor_id,
+		actor_type,
+		affected_resources,
+		metadata,
+		integrity_hash,
+		chain_sequence,
+		previous_hash
+	) VALUES `
+
+const insertLogPlaceholders = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// batchInsertSize bounds the rows of a multi-row insert, keeping its
+// parameters well under SQLite's limit.
+const batchInsertSize = 500
+
+// chainHead returns the sequence and integrity hash recorded for the last
+// log of a service's chain. The chain continues from it even when logs were
+// deleted from its end, so that they show as missing.
+func chainHead(ctx context.Context, tx *sql.Tx, serviceName string) (*persistence.ChainCheckpoint, error) {
+	head := &persistence.ChainCheckpoint{}
+
+	err := tx.QueryRowContext(ctx,
+		"SELECT sequence, integrity_hash FROM chain_heads WHERE service_name = ?",
+		serviceName,
+	).Scan(&head.Sequence, &head.IntegrityHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read chain head: %w", err)
+	}
+
+	return head, nil
+}
+
+// recordChainHead records head as the last log of a service's chain.
+func recordChainHead(ctx context.Context, tx *sql.Tx, serviceName string, head *persistence.ChainCheckpoint) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO chain_heads (service_name, sequence, integrity_hash) VALUES (?, ?, ?)
+		ON CONFLICT (service_name) DO UPDATE SET sequence = excluded.sequence, integrity_hash = excluded.integrity_hash`,
+		serviceName, head.Sequence, head.IntegrityHash)
+	if err != nil {
+		return fmt.Errorf("failed to record chain head of %s: %w", serviceName, err)
+	}
+
+	return nil
+}
+
+// chainLog links the log to the head of its chain and advances the head,
+// returning the values to insert and the log's integrity hash.
+func chainLog(head *persistence.ChainCheckpoint, log *core.Log) ([]any, string, error) {
+	integrityHash, err := core.ChainHash(head.IntegrityHash, log)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, "", fmt.Errorf("failed to compute integrity hash: %w", err)
 	}
-	defer stmt.Close()
 
-	timestampInt := log.Timestamp.Unix()
 	metadataJSON, err := json.Marshal(log.Metadata)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, "", fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
 	affectedResourcesJSON, err := json.Marshal(log.AffectedResources)
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal affected resources: %w", err)
+		return nil, "", fmt.Errorf("failed to marshal affected resources: %w", err)
 	}
 
-	res, err := stmt.ExecContext(ctx,
+	row := []any{
 		log.ID,
-		timestampInt,
+		log.Timestamp.Unix(),
+		log.Timestamp.Nanosecond(),
 		log.ServiceName,
 		log.Operation,
 		log.ActorId,
 		log.ActorType,
 		affectedResourcesJSON,
 		string(metadataJSON),
-		log.IntegrityHash,
-	)
+		integrityHash,
+		head.Sequence + 1,
+		head.IntegrityHash,
+	}
+
+	head.Sequence++
+	head.IntegrityHash = integrityHash
+
+	return row, integrityHash, nil
+}
+
+// resourceRows returns the rows of log_resources for the log, one per
+// distinct resource.
+func resourceRows(log *core.Log) [][]any {
+	rows := [][]any{}
+	seen := map[string]bool{}
+
+	for _, resource := range log.AffectedResources {
+		if seen[resource] {
+			continue
+		}
+		seen[resource] = true
+
+		rows = append(rows, []any{log.ID, resource})
+	}
+
+	return rows
+}
+
+// insertRows inserts rows with as few statements as possible, each one
+// being query followed by the placeholders of a chunk of rows.
+func insertRows(ctx context.Context, tx *sql.Tx, query string, placeholders string, rows [][]any) error {
+	for start := 0; start < len(rows); start += batchInsertSize {
+		chunk := rows[start:min(start+batchInsertSize, len(rows))]
+
+		values := make([]string, len(chunk))
+		args := []any{}
+		for i, row := range chunk {
+			values[i] = placeholders
+			args = append(args, row...)
+		}
+
+		if _, err := tx.ExecContext(ctx, query+strings.Join(values, ", "), args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertLogs inserts rows built by chainLog along with the rows of their
+// resources built by resourceRows.
+func insertLogs(ctx context.Context, tx *sql.Tx, rows [][]any, resources [][]any) error {
+	if err := insertRows(ctx, tx, insertLogQuery, insertLogPlaceholders, rows); err != nil {
+		return err
+	}
+
+	return insertRows(ctx, tx, "INSERT INTO log_resources (log_id, resource) VALUES ", "(?, ?)", resources)
+}
+
+// insertChainedLog appends the log to its service's hash chain, filling in
+// log.IntegrityHash from the hash of the current chain head.
+func insertChainedLog(ctx context.Context, tx *sql.Tx, log *core.Log) error {
+	head, err := chainHead(ctx, tx, log.ServiceName)
+	if err != nil {
+		return err
+	}
+
+	row, integrityHash, err := chainLog(head, log)
+	if err != nil {
+		return err
+	}
+
+	if err = insertLogs(ctx, tx, [][]any{row}, resourceRows(log)); err != nil {
+		return err
+	}
+
+	if err = recordChainHead(ctx, tx, log.ServiceName, head); err != nil {
+		return err
+	}
+
+	log.IntegrityHash = integrityHash
+
+	return nil
+}
+
+func (s *SQLitePersistence) persistChainedLog(ctx context.Context, log *core.Log) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = insertChainedLog(ctx, tx, log); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLitePersistence) PersistLog(ctx context.Context, log *core.Log) (*persistence.LogPersistenceResult, error) {
+	if err := log.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := s.persistChainedLog(ctx, log)
 	if err != nil {
 
 		if isUniqueConstraintError(err) {
@@ -225,109 +584,154 @@ func (s *SQLitePersistence) PersistLog(ctx context.Context, log *core.Log) (*per
 
 		return nil, fmt.Errorf("failed to execute statement: %w", err)
 	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get last insert id: %w", err)
-	}
 
+	// The log ID, like in BatchPersistLog, lets the agent match the result
+	// with the record it sent.
 	return &persistence.LogPersistenceResult{
-		ID:      fmt.Sprintf("%d", id),
+		ID:      log.ID.String(),
 		Success: true,
 	}, nil
 }
 
-func (s *SQLitePersistence) areLogsAlreadyPersisted(ctx context.Context, logIDs []string) (map[string]bool, error) {
-	existingLogs := make(map[string]bool)
-	for _, id := range logIDs {
-		var exists bool
-		err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM logs WHERE id = ?)", id).Scan(&exists)
+// persistedLogs returns which of the logs are already in the database.
+func persistedLogs(ctx context.Context, tx *sql.Tx, logs []*core.Log) (map[uuid.UUID]bool, error) {
+	persisted := map[uuid.UUID]bool{}
+
+	for start := 0; start < len(logs); start += batchInsertSize {
+		chunk := logs[start:min(start+batchInsertSize, len(logs))]
+
+		placeholders := make([]string, len(chunk))
+		args := make([]any, len(chunk))
+		for i, log := range chunk {
+			placeholders[i] = "?"
+			args[i] = log.ID
+		}
+
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM logs WHERE id IN ("+strings.Join(placeholders, ", ")+")", args...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to check if log with ID %s exists: %w", id, err)
+			return nil, fmt.Errorf("failed to find persisted logs: %w", err)
+		}
+
+		for rows.Next() {
+			var id uuid.UUID
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			persisted[id] = true
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, err
 		}
-		existingLogs[id] = exists
 	}
-	return existingLogs, nil
+
+	return persisted, nil
 }
 
-func (s *SQLitePersistence) BatchPersistLog(ctx context.Context, logs []*core.Log) ([]*persistence.LogPersistenceResult, error) {
-	query := `
-		INSERT INTO logs (
-			id,
-			timestamp,
-			service_name,
-			operation,
-			actor_id,
-			actor_type,
-			affected_resources,
-			metadata,
-			integrity_hash
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-	stmt, err := s.db.PrepareContext(ctx, query)
+// BatchPersistLog persists the logs in a single transaction. Logs already
+// persisted, or repeated in the batch, and invalid logs are left out and
+// reported as such. When the transaction fails, every log that was to be
+// persisted is reported as a storage failure, which the agent retries.
+func (s *SQLitePersistence) BatchPersistLog(ctx context.Context, logs []*core.Log, allOrNothing bool) ([]*persistence.LogPersistenceResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]*persistence.LogPersistenceResult, len(logs))
+	for i, log := range logs {
+		results[i] = &persistence.LogPersistenceResult{ID: log.ID.String(), Success: true}
+	}
+
+	// fail reports the logs that were to be persisted with reason.
+	fail := func(reason *core.Error) []*persistence.LogPersistenceResult {
+		for _, result := range results {
+			if result.Success {
+				result.Success = false
+				result.Reason = reason
+			}
+		}
+		return results
+	}
+
+	storageFailure := func(err error) []*persistence.LogPersistenceResult {
+		return fail(core.ErrorWithMessage(core.ErrorCodeStorageFailure, err.Error()))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+		return storageFailure(fmt.Errorf("failed to begin transaction: %w", err)), nil
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
+
+	persisted, err := persistedLogs(ctx, tx, logs)
+	if err != nil {
+		return storageFailure(err), nil
+	}
+
+	heads := map[string]*persistence.ChainCheckpoint{}
+	integrityHashes := make([]string, len(logs))
+	rows := [][]any{}
+	resources := [][]any{}
+	invalid := false
 
-	results := make([]*persistence.LogPersistenceResult, len(logs))
 	for i, log := range logs {
-		timestampInt := log.Timestamp.Unix()
-		metadataJSON, err := json.Marshal(log.Metadata)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		if persisted[log.ID] {
+			results[i].Success = false
+			results[i].Reason = core.ErrorAlreadyPersistedLog
+			continue
 		}
 
-		affectedResourcesJSON, err := json.Marshal(log.AffectedResources)
+		if err = log.Validate(); err != nil {
+			results[i].Success = false
+			results[i].Reason = err.(*core.Error)
+			invalid = true
+			continue
+		}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal affected resources: %w", err)
-		}
-
-		_, err = stmt.ExecContext(ctx,
-			log.ID,
-			timestampInt,
-			log.ServiceName,
-			log.Operation,
-			log.ActorId,
-			log.ActorType,
-			affectedResourcesJSON,
-			string(metadataJSON),
-			log.IntegrityHash,
-		)
-		if err != nil {
-			results[i] = &persistence.LogPersistenceResult{
-				ID:      log.ID.String(),
-				Success: false,
-				Reason:  core.ErrorAlreadyPersistedLog,
-			}
-		} else {
-			results[i] = &persistence.LogPersistenceResult{
-				ID:      log.ID.String(),
-				Success: true,
+		persisted[log.ID] = true
+
+		head, ok := heads[log.ServiceName]
+		if !ok {
+			if head, err = chainHead(ctx, tx, log.ServiceName); err != nil {
+				return storageFailure(err), nil
 			}
+			heads[log.ServiceName] = head
 		}
+
+		row, integrityHash, err := chainLog(head, log)
+		if err != nil {
+			return storageFailure(err), nil
+		}
+
+		rows = append(rows, row)
+		resources = append(resources, resourceRows(log)...)
+		integrityHashes[i] = integrityHash
 	}
 
-	return results, nil
-}
+	if invalid && allOrNothing {
+		return fail(core.ErrorBatchAborted), nil
+	}
 
-func createLogTable(db *sql.DB) error {
-	query := `
-CREATE TABLE IF NOT EXISTS logs (
-	id CHAR(36) PRIMARY KEY,
-	timestamp INTEGER NOT NULL,
-	service_name TEXT NOT NULL,
-	operation TEXT NOT NULL,
-	actor_id TEXT NOT NULL,
-	actor_type TEXT NOT NULL,
-	affected_resources TEXT NOT NULL,
-	metadata TEXT NOT NULL,
-	integrity_hash TEXT NOT NULL
-)
-	`
-	_, err := db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to create log table: %w", err)
+	if err = insertLogs(ctx, tx, rows, resources); err != nil {
+		return storageFailure(fmt.Errorf("failed to insert logs: %w", err)), nil
 	}
-	return nil
+
+	for service, head := range heads {
+		if err = recordChainHead(ctx, tx, service, head); err != nil {
+			return storageFailure(err), nil
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return storageFailure(fmt.Errorf("failed to commit transaction: %w", err)), nil
+	}
+
+	for i, log := range logs {
+		if results[i].Success {
+			log.IntegrityHash = integrityHashes[i]
+		}
+	}
+
+	return results, nil
 }
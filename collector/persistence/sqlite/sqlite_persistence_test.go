@@ -0,0 +1,255 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"oversee/collector/integrity"
+	"oversee/collector/persistence"
+	"oversee/core"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestLog(serviceName string, operation string, resources ...string) *core.Log {
+	return &core.Log{
+		ID:                uuid.New(),
+		Timestamp:         time.Now().UTC(),
+		ServiceName:       serviceName,
+		Operation:         operation,
+		ActorId:           "actor",
+		ActorType:         "user",
+		AffectedResources: resources,
+		Metadata:          map[string]any{"amount": 12.5},
+	}
+}
+
+// verifyChains fails the test when a hash chain of s is broken.
+func verifyChains(t *testing.T, s *SQLitePersistence) {
+	t.Helper()
+
+	report, err := integrity.Verify(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, service := range report.Services {
+		for _, issue := range service.Issues {
+			t.Errorf("%s: %s", service.ServiceName, issue)
+		}
+	}
+}
+
+func TestBatchPersistLog(t *testing.T) {
+	s := newTestPersistence(t)
+	ctx := context.Background()
+
+	persisted := newTestLog("billing", "invoice.create")
+	if _, err := s.PersistLog(ctx, persisted); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := newTestLog("billing", "invoice.pay")
+	invalid.Metadata = map[string]any{"amount": math.NaN()}
+
+	logs := []*core.Log{
+		newTestLog("billing", "invoice.pay"),
+		persisted,
+		invalid,
+		newTestLog("accounts", "user.create"),
+	}
+
+	results, err := s.BatchPersistLog(ctx, logs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCodes := []core.ErrorCode{core.ErrorCodeBatchAborted, core.ErrorCodeAlreadyPersistedLog, core.ErrorCodeInvalidLog, core.ErrorCodeBatchAborted}
+	for i, result := range results {
+		if result.Success || result.Reason.Code != wantCodes[i] {
+			t.Errorf("all-or-nothing result %d = %+v, want reason %d", i, result, wantCodes[i])
+		}
+	}
+
+	stored, err := s.ListLogs(ctx, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 1 {
+		t.Errorf("%d logs are stored after an aborted batch, want 1", len(stored))
+	}
+
+	results, err = s.BatchPersistLog(ctx, logs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, result := range results {
+		switch i {
+		case 0, 3:
+			if !result.Success || result.ID != logs[i].ID.String() {
+				t.Errorf("result %d = %+v, want success for %s", i, result, logs[i].ID)
+			}
+		default:
+			if result.Success || result.Reason.Code != wantCodes[i] {
+				t.Errorf("result %d = %+v, want reason %d", i, result, wantCodes[i])
+			}
+		}
+	}
+
+	if stored, err = s.ListLogs(ctx, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 3 {
+		t.Errorf("%d logs are stored, want 3", len(stored))
+	}
+
+	verifyChains(t, s)
+}
+
+func TestMigrateChainsExistingLogs(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := OpenSQLitePersistence(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// A database created before migrations existed only has the logs table.
+	if _, err = s.db.ExecContext(ctx, migrations[0].SQL); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		_, err = s.db.ExecContext(ctx,
+			"INSERT INTO logs (id, timestamp, service_name, operation, actor_id, actor_type, affected_resources, metadata, integrity_hash) VALUES (?, ?, 'billing', 'invoice.pay', 'actor', 'user', ?, '{}', 'agent hash')",
+			uuid.New(), time.Now().Unix()+int64(i), []byte(fmt.Sprintf(`["invoice/%d"]`, i)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	applied, err := s.Migrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != len(migrations) {
+		t.Errorf("Migrate() applied %d migrations, want %d", len(applied), len(migrations))
+	}
+
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := migrations[len(migrations)-1].Version; version != want {
+		t.Errorf("SchemaVersion() = %d, want %d", version, want)
+	}
+
+	if applied, err = s.Migrate(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("migrating again applied %d migrations, err %v, want none", len(applied), err)
+	}
+
+	head, err := s.GetChainHead(ctx, "billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if head.Sequence != 3 {
+		t.Errorf("the chain head is at sequence %d, want 3", head.Sequence)
+	}
+
+	if _, err = s.PersistLog(ctx, newTestLog("billing", "invoice.void")); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := s.ListChain(ctx, "billing", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, record := range records {
+		if record.Sequence != int64(i+1) {
+			t.Errorf("record %d has sequence %d, want %d", i, record.Sequence, i+1)
+		}
+	}
+
+	if len(records) != 4 {
+		t.Errorf("the chain has %d records, want 4", len(records))
+	}
+
+	found, err := s.SearchLogs(ctx, persistence.SearchQuery{AffectedResources: []string{"invoice/1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 1 {
+		t.Errorf("found %d logs by the resource of an existing log, want 1", len(found))
+	}
+
+	verifyChains(t, s)
+}
+
+func TestSearchLogs(t *testing.T) {
+	s := newTestPersistence(t)
+	ctx := context.Background()
+
+	logs := []*core.Log{
+		newTestLog("billing", "invoice.pay", "org/1", "org/1/invoice/1"),
+		newTestLog("billing", "invoice.pay", "org/2/invoice/2"),
+		newTestLog("billing", "invoice.void", "org/1"),
+		newTestLog("accounts", "user.create", "org/1/user/1"),
+	}
+
+	for i, log := range logs {
+		log.Timestamp = log.Timestamp.Add(time.Duration(i) * time.Second)
+		if _, err := s.PersistLog(ctx, log); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query persistence.SearchQuery
+		want  []*core.Log
+	}{
+		{"service", persistence.SearchQuery{ServiceName: "billing"}, []*core.Log{logs[2], logs[1], logs[0]}},
+		{"operation", persistence.SearchQuery{ServiceName: "billing", Operation: "invoice.pay"}, []*core.Log{logs[1], logs[0]}},
+		{"resource", persistence.SearchQuery{AffectedResources: []string{"org/1"}}, []*core.Log{logs[2], logs[0]}},
+		{"resource prefix", persistence.SearchQuery{AffectedResources: []string{"org/1/*"}}, []*core.Log{logs[3], logs[0]}},
+		{"any of resources", persistence.SearchQuery{AffectedResources: []string{"org/2/*", "org/1/user/1"}}, []*core.Log{logs[3], logs[1]}},
+		{"all of resources", persistence.SearchQuery{AffectedResources: []string{"org/1", "org/1/invoice/*"}, ResourceMatch: persistence.ResourceMatchAll}, []*core.Log{logs[0]}},
+		{"after cursor", persistence.SearchQuery{ServiceName: "billing", CursorTimestamp: logs[1].Timestamp.Unix(), CursorID: logs[1].ID.String()}, []*core.Log{logs[0]}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := s.SearchLogs(ctx, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []uuid.UUID{}
+			for _, log := range found {
+				got = append(got, log.ID)
+			}
+
+			want := []uuid.UUID{}
+			for _, log := range test.want {
+				want = append(want, log.ID)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("SearchLogs() = %v, want %v", got, want)
+			}
+		})
+	}
+}
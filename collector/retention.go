@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"oversee/collector/persistence"
+	"time"
+)
+
+// EnforceRetention purges logs older than the configured maximum age every
+// interval until ctx is done. It returns immediately when logs are kept
+// forever.
+func EnforceRetention(ctx context.Context, p persistence.Persistence, config RetentionConfig) {
+	if config.MaxAge <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.PurgeLogs(ctx, time.Now().Add(-config.MaxAge))
+		if err != nil {
+			fmt.Println("Failed to purge expired logs:", err)
+		} else if purged > 0 {
+			fmt.Println("Purged", purged, "expired logs")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LogSchemaVersion is the version of the canonical log encoding. It must be
+// bumped whenever the encoding changes, since integrity hashes and
+// signatures are computed over it.
+const LogSchemaVersion = 1
+
+// maxExactInteger is the largest magnitude up to which float64 holds every
+// integer exactly.
+const maxExactInteger = 1 << 53
+
+// canonicalTimeLayout always renders nanoseconds so that a timestamp has a
+// single textual representation.
+const canonicalTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// canonicalLog fixes the field order of the encoding. Map keys in Metadata
+// are sorted by encoding/json.
+type canonicalLog struct {
+	Version           int            `json:"v"`
+	ID                string         `json:"id"`
+	Timestamp         string         `json:"timestamp"`
+	ServiceName       string         `json:"service_name"`
+	Operation         string         `json:"operation"`
+	ActorId           string         `json:"actor_id"`
+	ActorType         string         `json:"actor_type"`
+	AffectedResources []string       `json:"affected_resources"`
+	Metadata          map[string]any `json:"metadata"`
+	IntegrityHash     string         `json:"integrity_hash"`
+}
+
+// Canonical returns the deterministic encoding of the log: fields in a fixed
+// order, timestamps in UTC with nanosecond precision and metadata normalized
+// by NormalizeMetadata. Decoding the result with DecodeLog and encoding it
+// again yields the same bytes.
+func (l *Log) Canonical() ([]byte, error) {
+	metadata, err := NormalizeMetadata(l.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	affectedResources := l.AffectedResources
+	if affectedResources == nil {
+		affectedResources = []string{}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	err = encoder.Encode(canonicalLog{
+		Version:           LogSchemaVersion,
+		ID:                l.ID.String(),
+		Timestamp:         l.Timestamp.UTC().Format(canonicalTimeLayout),
+		ServiceName:       l.ServiceName,
+		Operation:         l.Operation,
+		ActorId:           l.ActorId,
+		ActorType:         l.ActorType,
+		AffectedResources: affectedResources,
+		Metadata:          metadata,
+		IntegrityHash:     l.IntegrityHash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode log %s: %w", l.ID, err)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// DecodeLog decodes a log produced by Canonical. Logs encoded before the
+// canonical encoding existed, which carry no version field, are accepted as
+// well.
+func DecodeLog(data []byte) (*Log, error) {
+	var version struct {
+		Version *int `json:"v"`
+	}
+
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, fmt.Errorf("failed to decode log: %w", err)
+	}
+
+	if version.Version == nil {
+		log := &Log{}
+		if err := json.Unmarshal(data, log); err != nil {
+			return nil, fmt.Errorf("failed to decode legacy log: %w", err)
+		}
+		return log, nil
+	}
+
+	if *version.Version != LogSchemaVersion {
+		return nil, fmt.Errorf("unsupported log schema version %d", *version.Version)
+	}
+
+	encoded := canonicalLog{}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("failed to decode log: %w", err)
+	}
+
+	id, err := uuid.Parse(encoded.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log id: %w", err)
+	}
+
+	timestamp, err := time.Parse(canonicalTimeLayout, encoded.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log timestamp: %w", err)
+	}
+
+	metadata := encoded.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	return &Log{
+		ID:                id,
+		Timestamp:         timestamp,
+		ServiceName:       encoded.ServiceName,
+		Operation:         encoded.Operation,
+		ActorId:           encoded.ActorId,
+		ActorType:         encoded.ActorType,
+		AffectedResources: encoded.AffectedResources,
+		Metadata:          metadata,
+		IntegrityHash:     encoded.IntegrityHash,
+	}, nil
+}
+
+// NormalizeMetadata reduces metadata to what survives a trip through structpb
+// or JSON unchanged. Numbers become float64, except integers float64 cannot
+// hold exactly, which become their decimal string so that no digit is lost.
+func NormalizeMetadata(metadata map[string]any) (map[string]any, error) {
+	normalized := make(map[string]any, len(metadata))
+	for key, value := range metadata {
+		v, err := normalizeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %q: %w", key, err)
+		}
+		normalized[key] = v
+	}
+	return normalized, nil
+}
+
+func normalizeValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, string, bool:
+		return v, nil
+	case float64:
+		return normalizeFloat(v)
+	case float32:
+		return normalizeFloat(float64(v))
+	case int, int8, int16, int32, int64:
+		return normalizeInteger(reflect.ValueOf(v).Int()), nil
+	case uint, uint8, uint16, uint32, uint64:
+		u := reflect.ValueOf(v).Uint()
+		if u > maxExactInteger {
+			return strconv.FormatUint(u, 10), nil
+		}
+		return float64(u), nil
+	case json.Number:
+		// Integers are kept digit for digit, whatever their size.
+		if isInteger(string(v)) {
+			if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+				return normalizeInteger(i), nil
+			}
+			return string(v), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return normalizeFloat(f)
+	case map[string]any:
+		return NormalizeMetadata(v)
+	case []any:
+		normalized := make([]any, len(v))
+		for i, item := range v {
+			n, err := normalizeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			normalized[i] = n
+		}
+		return normalized, nil
+	default:
+		// Structs, typed maps and slices are reduced to their generic JSON
+		// form so they encode the same way once decoded.
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		var generic any
+		if err := decoder.Decode(&generic); err != nil {
+			return nil, err
+		}
+		return normalizeValue(generic)
+	}
+}
+
+// normalizeInteger returns the integer as a float64, which encodes the same
+// way, when it holds it exactly, and as its decimal string otherwise.
+func normalizeInteger(i int64) any {
+	if i > maxExactInteger || i < -maxExactInteger {
+		return strconv.FormatInt(i, 10)
+	}
+	return float64(i)
+}
+
+// isInteger reports whether s is an integer as written in JSON.
+func isInteger(s string) bool {
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || (digits[0] == '0' && len(digits) > 1) {
+		return false
+	}
+
+	return strings.Trim(digits, "0123456789") == ""
+}
+
+func normalizeFloat(f float64) (any, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported number %v", f)
+	}
+	return f, nil
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newCanonicalTestLog(metadata map[string]any) *Log {
+	return &Log{
+		ID:                uuid.MustParse("6f1c2a3e-8a4b-4b8e-9d43-2c1f0e6a7b90"),
+		Timestamp:         time.Date(2024, 3, 1, 12, 30, 0, 123, time.UTC),
+		ServiceName:       "billing",
+		Operation:         "invoice.pay",
+		ActorId:           "user-1",
+		ActorType:         "user",
+		AffectedResources: []string{"invoice/1", "customer/1"},
+		Metadata:          metadata,
+	}
+}
+
+func canonical(t *testing.T, log *Log) []byte {
+	t.Helper()
+
+	b, err := log.Canonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestCanonicalRoundTrip(t *testing.T) {
+	log := newCanonicalTestLog(map[string]any{
+		"amount":   12.5,
+		"count":    3,
+		"paid":     true,
+		"note":     "<paid> & done",
+		"refund":   nil,
+		"items":    []any{map[string]any{"sku": "a-1", "quantity": 2}, "gift"},
+		"customer": map[string]any{"id": 42, "tags": []any{"vip"}},
+	})
+	log.IntegrityHash = "hash"
+
+	encoded := canonical(t, log)
+
+	decoded, err := DecodeLog(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(canonical(t, decoded), encoded) {
+		t.Errorf("encoding a decoded log gave %s, want %s", canonical(t, decoded), encoded)
+	}
+
+	if decoded.ID != log.ID || !decoded.Timestamp.Equal(log.Timestamp) || decoded.IntegrityHash != log.IntegrityHash ||
+		!reflect.DeepEqual(decoded.AffectedResources, log.AffectedResources) {
+		t.Errorf("DecodeLog() = %+v, want %+v", decoded, log)
+	}
+}
+
+func TestCanonicalIsDeterministic(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	first := map[string]any{}
+	second := map[string]any{}
+	for i, key := range keys {
+		first[key] = map[string]any{"position": i, "key": key}
+		second[keys[len(keys)-1-i]] = map[string]any{"key": keys[len(keys)-1-i], "position": len(keys) - 1 - i}
+	}
+
+	want := canonical(t, newCanonicalTestLog(first))
+	for range 20 {
+		if got := canonical(t, newCanonicalTestLog(second)); !bytes.Equal(got, want) {
+			t.Fatalf("the same metadata encoded to %s and %s", got, want)
+		}
+	}
+
+	if !bytes.Contains(want, []byte(`"metadata":{"a":{"key":"a","position":0},"b":`)) {
+		t.Errorf("metadata keys are not sorted in %s", want)
+	}
+
+	// A number encodes the same whatever type it was given as.
+	for _, number := range []any{3, int64(3), uint8(3), float32(3), json.Number("3")} {
+		got := canonical(t, newCanonicalTestLog(map[string]any{"n": number}))
+		if want := canonical(t, newCanonicalTestLog(map[string]any{"n": 3.0})); !bytes.Equal(got, want) {
+			t.Errorf("%T 3 encoded to %s, want %s", number, got, want)
+		}
+	}
+}
+
+func TestCanonicalVersion(t *testing.T) {
+	encoded := canonical(t, newCanonicalTestLog(map[string]any{}))
+
+	if !bytes.HasPrefix(encoded, []byte(`{"v":1,"id":`)) {
+		t.Errorf("the encoding %s does not start with its version", encoded)
+	}
+
+	newer := bytes.Replace(encoded, []byte(`{"v":1,`), []byte(`{"v":2,`), 1)
+	if _, err := DecodeLog(newer); err == nil {
+		t.Error("a log of an unknown schema version was decoded")
+	}
+
+	legacy, err := json.Marshal(newCanonicalTestLog(map[string]any{"amount": 12.5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := DecodeLog(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Operation != "invoice.pay" || log.Metadata["amount"] != 12.5 {
+		t.Errorf("DecodeLog() = %+v for a log without version", log)
+	}
+}
+
+func TestCanonicalIntegersAreLossless(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{int64(math.MaxInt64), `"9223372036854775807"`},
+		{int64(math.MinInt64), `"-9223372036854775808"`},
+		{uint64(math.MaxUint64), `"18446744073709551615"`},
+		{int64(1<<53 + 1), `"9007199254740993"`},
+		{int64(1 << 53), `9007199254740992`},
+		{-(1 << 53), `-9007199254740992`},
+		{json.Number("123456789012345678901234567890"), `"123456789012345678901234567890"`},
+		{json.Number("1.5e3"), `1500`},
+	}
+
+	for _, test := range tests {
+		log := newCanonicalTestLog(map[string]any{"n": test.value})
+		encoded := canonical(t, log)
+
+		if !strings.Contains(string(encoded), `"metadata":{"n":`+test.want+`}`) {
+			t.Errorf("%T %v encoded to %s, want metadata n %s", test.value, test.value, encoded, test.want)
+		}
+
+		// The agent buffers the encoding and sends the metadata as a
+		// structpb, both of which must give back the same encoding.
+		decoded, err := DecodeLog(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		normalized, err := NormalizeMetadata(log.Metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		metadata, err := structpb.NewStruct(normalized)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sent := newCanonicalTestLog(metadata.AsMap())
+
+		for _, got := range [][]byte{canonical(t, decoded), canonical(t, sent)} {
+			if !bytes.Equal(got, encoded) {
+				t.Errorf("%T %v encoded to %s after a trip, want %s", test.value, test.value, got, encoded)
+			}
+		}
+	}
+}
+
+func TestCanonicalRejectsNonFiniteNumbers(t *testing.T) {
+	for _, number := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := newCanonicalTestLog(map[string]any{"n": number}).Canonical(); err == nil {
+			t.Errorf("%v was encoded", number)
+		}
+	}
+}
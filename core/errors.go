@@ -11,6 +11,18 @@ type ErrorCode int
 const (
 	ErrorCodeAlreadyPersistedLog = iota + 1001
 	ErrorCodeInvalidErrorFormat
+	ErrorCodeUnsignedRequest
+	ErrorCodeUnknownAgent
+	ErrorCodeInvalidSignature
+	ErrorCodeAgentIdentityMismatch
+	// ErrorCodeInvalidLog is a log that can never be persisted as sent.
+	ErrorCodeInvalidLog
+	// ErrorCodeStorageFailure is a log the collector failed to write, which
+	// may be persisted when sent again.
+	ErrorCodeStorageFailure
+	// ErrorCodeBatchAborted is a valid log left out of an all-or-nothing
+	// batch because of another log of the batch.
+	ErrorCodeBatchAborted
 )
 
 type Error struct {
@@ -58,3 +70,10 @@ func ParseString(s string) (*Error, error) {
 }
 
 var ErrorAlreadyPersistedLog = ErrorWithMessage(ErrorCodeAlreadyPersistedLog, "Already Persisted")
+var ErrorUnsignedRequest = ErrorWithMessage(ErrorCodeUnsignedRequest, "Request Is Not Signed")
+var ErrorUnknownAgent = ErrorWithMessage(ErrorCodeUnknownAgent, "Agent Is Not Trusted")
+var ErrorInvalidSignature = ErrorWithMessage(ErrorCodeInvalidSignature, "Invalid Signature")
+var ErrorAgentIdentityMismatch = ErrorWithMessage(ErrorCodeAgentIdentityMismatch, "Agent Does Not Match Client Certificate")
+var ErrorInvalidLog = ErrorWithMessage(ErrorCodeInvalidLog, "Invalid Log")
+var ErrorStorageFailure = ErrorWithMessage(ErrorCodeStorageFailure, "Storage Failure")
+var ErrorBatchAborted = ErrorWithMessage(ErrorCodeBatchAborted, "Batch Aborted")
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ChainHash computes the integrity hash of a log as the SHA-256 of the
+// previous log's hash followed by the canonical encoding of the log without
+// its own hash. The first log of a chain uses an empty previous hash. The
+// canonical encoding lists its fields explicitly and is versioned by
+// LogSchemaVersion, so adding fields to Log never changes the hash of stored
+// logs.
+func ChainHash(previousHash string, log *Log) (string, error) {
+	unhashed := *log
+	unhashed.IntegrityHash = ""
+
+	payload, err := unhashed.Canonical()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(previousHash))
+	h.Write(payload)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
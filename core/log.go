@@ -1,7 +1,7 @@
 package core
 
 import (
-	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,10 +20,23 @@ type Log struct {
 }
 
 func (l *Log) String() string {
-	return string(l.Bytes())
+	b, err := l.Canonical()
+	if err != nil {
+		return fmt.Sprintf("<invalid log %s: %v>", l.ID, err)
+	}
+	return string(b)
 }
 
-func (l *Log) Bytes() []byte {
-	s, _ := json.Marshal(l)
-	return s
+// Validate checks that the log can be persisted, which takes an ID and a
+// body with a canonical encoding since both go into its integrity hash.
+func (l *Log) Validate() error {
+	if l.ID == uuid.Nil {
+		return ErrorWithMessage(ErrorCodeInvalidLog, "Log ID Is Missing Or Not A UUID")
+	}
+
+	if _, err := l.Canonical(); err != nil {
+		return ErrorWithMessage(ErrorCodeInvalidLog, err.Error())
+	}
+
+	return nil
 }
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+)
+
+const signatureContext = "oversee-logs-v1"
+
+// signaturePayload is the message an agent signs for a set of logs: a
+// context string, the agent ID and the canonical encoding of every log, in
+// order, each on its own line.
+func signaturePayload(agentID string, logs []*Log) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(signatureContext)
+	buf.WriteByte('\n')
+	buf.WriteString(agentID)
+	buf.WriteByte('\n')
+
+	for _, log := range logs {
+		b, err := log.Canonical()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SignLogs signs the logs as dispatched by the given agent.
+func SignLogs(key ed25519.PrivateKey, agentID string, logs []*Log) ([]byte, error) {
+	payload, err := signaturePayload(agentID, logs)
+	if err != nil {
+		return nil, err
+	}
+
+	return ed25519.Sign(key, payload), nil
+}
+
+// VerifyLogs checks a signature produced by SignLogs, returning
+// ErrorInvalidSignature when it does not match.
+func VerifyLogs(key ed25519.PublicKey, agentID string, logs []*Log, signature []byte) error {
+	if len(signature) == 0 {
+		return ErrorUnsignedRequest
+	}
+
+	payload, err := signaturePayload(agentID, logs)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key, payload, signature) {
+		return ErrorInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package lifecycle
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// StopGRPCServer lets in-flight RPCs of the server finish, forcing it to
+// stop once ctx is done.
+func StopGRPCServer(ctx context.Context, s *grpc.Server) {
+	stopped := make(chan struct{})
+
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Stop()
+		<-stopped
+	}
+}
@@ -0,0 +1,175 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+)
+
+// Config holds the TLS material of one side of a gRPC connection. A Config
+// without certificate nor CA means plaintext.
+type Config struct {
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	CAFile   string `yaml:"ca_file,omitempty"`
+	// RequireClientCert makes a server reject clients without a certificate
+	// signed by CAFile.
+	RequireClientCert bool `yaml:"require_client_cert,omitempty"`
+	// ServerName overrides the name a client verifies the server
+	// certificate against.
+	ServerName string `yaml:"server_name,omitempty"`
+}
+
+// RegisterFlags binds the config to flags named <prefix>tls-cert,
+// <prefix>tls-key, and so on.
+func (c *Config) RegisterFlags(flags *flag.FlagSet, prefix string) {
+	flags.StringVar(&c.CertFile, prefix+"tls-cert", c.CertFile, "path to the PEM certificate")
+	flags.StringVar(&c.KeyFile, prefix+"tls-key", c.KeyFile, "path to the PEM private key")
+	flags.StringVar(&c.CAFile, prefix+"tls-ca", c.CAFile, "path to the PEM CA bundle used to verify the peer")
+	flags.BoolVar(&c.RequireClientCert, prefix+"tls-client-auth", c.RequireClientCert, "require clients to present a certificate signed by the CA")
+	flags.StringVar(&c.ServerName, prefix+"tls-server-name", c.ServerName, "server name to verify the server certificate against")
+}
+
+func (c *Config) Enabled() bool {
+	return c != nil && (c.CertFile != "" || c.CAFile != "")
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("tls certificate and key must be set together")
+	}
+
+	if c.RequireClientCert && c.CAFile == "" {
+		return fmt.Errorf("tls client auth requires a CA")
+	}
+
+	return nil
+}
+
+func (c *Config) certPool() (*x509.CertPool, error) {
+	pem, err := os.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+	}
+
+	return pool, nil
+}
+
+// ServerCredentials returns the transport credentials of a gRPC server,
+// plaintext when TLS is not configured.
+func (c *Config) ServerCredentials() (credentials.TransportCredentials, error) {
+	if !c.Enabled() {
+		return insecure.NewCredentials(), nil
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	if c.CertFile == "" {
+		return nil, fmt.Errorf("tls server requires a certificate")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.CAFile != "" {
+		config.ClientCAs, err = c.certPool()
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if c.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// ClientCredentials returns the transport credentials of a gRPC client,
+// plaintext when TLS is not configured. The system roots verify the server
+// when no CA is set.
+func (c *Config) ClientCredentials() (credentials.TransportCredentials, error) {
+	if !c.Enabled() {
+		return insecure.NewCredentials(), nil
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		ServerName: c.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CAFile != "" {
+		pool, err := c.certPool()
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// PeerIdentity returns the identity of the client of a gRPC call, taken from
+// the common name of its verified certificate, or its first DNS name when
+// the common name is empty.
+func PeerIdentity(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName, true
+	}
+
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0], true
+	}
+
+	return "", false
+}
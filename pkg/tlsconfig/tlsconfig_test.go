@@ -0,0 +1,260 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCA signs the certificates of a test, written as PEM files to its
+// temporary directory.
+type testCA struct {
+	t    *testing.T
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "oversee test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &testCA{t: t, dir: t.TempDir(), cert: cert, key: key}
+	ca.file = ca.write("ca.pem", "CERTIFICATE", der)
+
+	return ca
+}
+
+func (ca *testCA) write(name string, blockType string, der []byte) string {
+	ca.t.Helper()
+
+	path := filepath.Join(ca.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		ca.t.Fatal(err)
+	}
+
+	return path
+}
+
+// issue signs a certificate for name, valid for localhost, and returns the
+// paths of the certificate and its key.
+func (ca *testCA) issue(name string, usage x509.ExtKeyUsage) (string, string) {
+	ca.t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+
+	return ca.write(name+".pem", "CERTIFICATE", der), ca.write(name+".key", "EC PRIVATE KEY", keyDER)
+}
+
+// serve starts a gRPC health server with the server config and returns its
+// address, along with the identities of the clients of its calls.
+func serve(t *testing.T, config *Config) (string, chan string) {
+	t.Helper()
+
+	creds, err := config.ServerCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identities := make(chan string, 1)
+	s := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(
+		func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			identity, _ := PeerIdentity(ctx)
+			identities <- identity
+			return handler(ctx, req)
+		},
+	))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	return listener.Addr().String(), identities
+}
+
+// check calls the health service at address with the client config.
+func check(t *testing.T, address string, config *Config) error {
+	t.Helper()
+
+	creds, err := config.ClientCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMutualTLSIdentifiesClient(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue("collector", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue("agent-1", x509.ExtKeyUsageClientAuth)
+
+	address, identities := serve(t, &Config{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file, RequireClientCert: true})
+
+	err := check(t, address, &Config{CertFile: clientCert, KeyFile: clientKey, CAFile: ca.file, ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity := <-identities; identity != "agent-1" {
+		t.Errorf("PeerIdentity() = %q, want %q", identity, "agent-1")
+	}
+}
+
+func TestMutualTLSRejectsClientWithoutCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue("collector", x509.ExtKeyUsageServerAuth)
+
+	address, _ := serve(t, &Config{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file, RequireClientCert: true})
+
+	if err := check(t, address, &Config{CAFile: ca.file, ServerName: "localhost"}); err == nil {
+		t.Error("a client without certificate was accepted")
+	}
+}
+
+func TestMutualTLSRejectsClientOfAnotherCA(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue("collector", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := newTestCA(t).issue("agent-1", x509.ExtKeyUsageClientAuth)
+
+	address, _ := serve(t, &Config{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file, RequireClientCert: true})
+
+	if err := check(t, address, &Config{CertFile: clientCert, KeyFile: clientKey, CAFile: ca.file, ServerName: "localhost"}); err == nil {
+		t.Error("a client with a certificate of another CA was accepted")
+	}
+}
+
+func TestTLSWithoutClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue("collector", x509.ExtKeyUsageServerAuth)
+
+	address, identities := serve(t, &Config{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file})
+
+	if err := check(t, address, &Config{CAFile: ca.file, ServerName: "localhost"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if identity := <-identities; identity != "" {
+		t.Errorf("PeerIdentity() = %q for a client without certificate", identity)
+	}
+}
+
+func TestTLSRejectsUntrustedServer(t *testing.T) {
+	serverCert, serverKey := newTestCA(t).issue("collector", x509.ExtKeyUsageServerAuth)
+
+	address, _ := serve(t, &Config{CertFile: serverCert, KeyFile: serverKey})
+
+	if err := check(t, address, &Config{CAFile: newTestCA(t).file, ServerName: "localhost"}); err == nil {
+		t.Error("a server with a certificate of an untrusted CA was accepted")
+	}
+}
+
+func TestPlaintext(t *testing.T) {
+	address, identities := serve(t, &Config{})
+
+	if err := check(t, address, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if identity := <-identities; identity != "" {
+		t.Errorf("PeerIdentity() = %q over plaintext", identity)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"plaintext", Config{}, true},
+		{"certificate without key", Config{CertFile: "cert.pem"}, false},
+		{"client auth without CA", Config{CertFile: "cert.pem", KeyFile: "key.pem", RequireClientCert: true}, false},
+		{"mutual TLS", Config{CertFile: "cert.pem", KeyFile: "key.pem", CAFile: "ca.pem", RequireClientCert: true}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.config.Validate(); (err == nil) != test.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}